@@ -4,8 +4,10 @@ Copyright © 2025 koooyooo
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 
 	"github.com/koooyooo/mdai/config"
@@ -18,31 +20,66 @@ var answerCmd = &cobra.Command{
 	Use:   "answer",
 	Short: "Answer the question based on the content of a markdown file",
 	Long: `Answer the question based on the content of a markdown file.
-	The question will be extracted from the last quote in the file.
-	The answer will be appended to the end of the file.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	The question will be extracted from the last block quote in the file,
+	including multi-line, nested (>>) and lazy continuation lines.
+	Earlier block quotes are kept in the context as prior questions.
+	The answer will be appended to the end of the file, between answer markers.
+	Earlier answered questions are sent as a conversation history.
+	With --all, every question without an answer yet is answered in document order,
+	using the content above it as context, and each answer is inserted right after its question.
+	With --redo, the most recent answer is replaced in place with a new one,
+	optionally using a different --model or --temperature.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := config.GetInstance().GetConfig()
 		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 			Level: cfg.Default.GetLogLevel().Level(),
 		}))
-		if err := answer(cfg, args, logger); err != nil {
+		all, _ := cmd.Flags().GetBool("all")
+		redo, _ := cmd.Flags().GetBool("redo")
+		// The flags take precedence over the settings of the operation, so they are set on it
+		if op, exists := cfg.Append.Operations["answer"]; exists {
+			if model, _ := cmd.Flags().GetString("model"); model != "" {
+				op.Model = model
+			}
+			if cmd.Flags().Changed("temperature") {
+				temperature, _ := cmd.Flags().GetFloat64("temperature")
+				op.Quality.Temperature = &temperature
+			}
+			operations := maps.Clone(cfg.Append.Operations)
+			operations["answer"] = op
+			cfg.Append.Operations = operations
+		}
+		if err := answer(cmd.Context(), cfg, args, all, redo, logger); err != nil {
 			logger.Error("fail in calling answer", "error", err)
+			return reported(err)
 		}
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(answerCmd)
+	answerCmd.Flags().Bool("all", false, "Answer every unanswered question in the file")
+	answerCmd.Flags().Bool("redo", false, "Replace the most recent answer with a new one")
+	answerCmd.Flags().String("model", "", "Model used for the answer (default: the operation's model or default.model)")
+	answerCmd.Flags().Float64("temperature", 0, "Temperature used for the answer (default: the operation's temperature or default.quality.temperature)")
+	answerCmd.MarkFlagsMutuallyExclusive("all", "redo")
 }
 
-func answer(cfg config.Config, args []string, logger *slog.Logger) error {
+func answer(ctx context.Context, cfg config.Config, args []string, all, redo bool, logger *slog.Logger) error {
 	if len(args) == 0 {
 		return fmt.Errorf("path is required")
 	}
 
 	path := args[0]
+	if all {
+		return controller.AppendAll(ctx, cfg, "answer", path, logger)
+	}
+	if redo {
+		return controller.Redo(ctx, cfg, "answer", path, logger)
+	}
 	extraArgs := []string{}
 
 	// Call append controller directly
-	return controller.Append(cfg, "answer", path, extraArgs, logger)
+	return controller.Append(ctx, cfg, "answer", path, extraArgs, logger)
 }
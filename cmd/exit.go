@@ -0,0 +1,77 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/koooyooo/mdai/controller"
+	"github.com/koooyooo/mdai/provider"
+)
+
+// Exit codes of mdai, so that scripts can tell the failures apart
+const (
+	ExitOK             = 0
+	ExitError          = 1
+	ExitUsage          = 2
+	ExitAuth           = 3
+	ExitRateLimit      = 4
+	ExitContextLength  = 5
+	ExitServer         = 6
+	ExitNetwork        = 7
+	ExitBudgetExceeded = 8
+	ExitCanceled       = 130
+)
+
+// reportedError marks an error already logged by the command, so that Execute does not print it again
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reportedError) Unwrap() error {
+	return e.err
+}
+
+// reported marks err as logged by the command
+func reported(err error) error {
+	return &reportedError{err: err}
+}
+
+// exitCode maps the error returned by a command to the exit code of the process.
+// Errors not returned by a command (unknown commands, invalid flags or arguments) are usage errors.
+func exitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	if errors.Is(err, context.Canceled) {
+		return ExitCanceled
+	}
+	if errors.Is(err, controller.ErrBudgetExceeded) {
+		return ExitBudgetExceeded
+	}
+	if apiErr, ok := provider.AsError(err); ok {
+		switch apiErr.Kind {
+		case provider.ErrorKindAuth:
+			return ExitAuth
+		case provider.ErrorKindRateLimit:
+			return ExitRateLimit
+		case provider.ErrorKindContextLength:
+			return ExitContextLength
+		case provider.ErrorKindServer:
+			return ExitServer
+		case provider.ErrorKindNetwork:
+			return ExitNetwork
+		}
+	}
+	var r *reportedError
+	if !errors.As(err, &r) {
+		return ExitUsage
+	}
+	return ExitError
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history [file]",
+	Short: "List the snapshots recorded in the undo journal",
+	Long: `List the snapshots recorded in the undo journal (~/.mdai/journal), oldest first.
+Each snapshot is the content of a file before mdai wrote it; mdai undo restores the latest one.
+
+For example:
+  mdai history notes.md
+  mdai history`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.GetInstance().GetConfig()
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: cfg.Default.GetLogLevel().Level(),
+		}))
+		if err := history(cfg, os.Stdout, args); err != nil {
+			logger.Error("fail in calling history", "error", err)
+			return reported(err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
+
+func history(cfg config.Config, w io.Writer, args []string) error {
+	j, err := openJournal(cfg)
+	if err != nil {
+		return err
+	}
+
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+	entries, err := j.Entries(path)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "no history")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tOPERATION\tSIZE\tHASH\tFILE")
+	for _, e := range entries {
+		size, hash := "-", "(new file)"
+		if e.Exists {
+			size = fmt.Sprintf("%d", e.Size)
+			hash = e.Hash[:12]
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Timestamp.Format("2006-01-02 15:04:05"), e.Operation, size, hash, e.Path)
+	}
+	return tw.Flush()
+}
@@ -26,14 +26,15 @@ This command will:
 1. Create ~/.mdai directory if it doesn't exist
 2. Copy config.sample.yml to ~/.mdai/config.yml if it doesn't exist
 3. Display the path of the created config file`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 			Level: slog.LevelInfo,
 		}))
 		if err := initConfig(logger); err != nil {
 			logger.Error("fail in calling init", "error", err)
-			os.Exit(1)
+			return reported(err)
 		}
+		return nil
 	},
 }
 
@@ -0,0 +1,55 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/controller"
+	"github.com/spf13/cobra"
+)
+
+// renderCmd represents the render command
+var renderCmd = &cobra.Command{
+	Use:   "render [file|dir]",
+	Short: "Fill or refresh the directive regions of markdown files",
+	Long: `Fill or refresh the directive regions of a markdown file, or of every markdown file under a directory.
+A directive region runs an operation from the config file and holds its result:
+
+  <!-- mdai:summarize section="Background" -->
+  ...
+  <!-- /mdai -->
+
+The section attribute limits the input to the section under the heading, args passes extra arguments
+to the operation (e.g. args="ja" for translate), and the whole document is used otherwise.
+Each region records the hash of its input, so that unchanged regions are skipped on reruns.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.GetInstance().GetConfig()
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: cfg.Default.GetLogLevel().Level(),
+		}))
+		force, _ := cmd.Flags().GetBool("force")
+		if err := render(cmd.Context(), cfg, args, force, logger); err != nil {
+			logger.Error("fail in calling render", "error", err)
+			return reported(err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+	renderCmd.Flags().Bool("force", false, "Regenerate every region, even when its input is unchanged")
+}
+
+func render(ctx context.Context, cfg config.Config, args []string, force bool, logger *slog.Logger) error {
+	if len(args) == 0 {
+		return fmt.Errorf("path is required")
+	}
+	return controller.Render(ctx, cfg, args[0], force, logger)
+}
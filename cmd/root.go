@@ -4,7 +4,14 @@ Copyright © 2025 koooyooo
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/koooyooo/mdai/config"
 
 	"github.com/spf13/cobra"
 )
@@ -22,14 +29,35 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+
+	// Commands log their own errors; Execute maps them to the exit code
+	SilenceUsage:  true,
+	SilenceErrors: true,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// The context of the commands is cancelled on SIGINT or SIGTERM; a second signal terminates immediately.
+// Operations defined in the config file without a command of their own are added as commands.
+// The process exits with a code telling the class of the failure (see exitCode).
 func Execute() {
-	err := rootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	addOperationCommands(config.GetInstance().GetConfig())
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	var r *reportedError
+	if err != nil && !errors.As(err, &r) {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		fmt.Fprintf(os.Stderr, "Run '%s --help' for usage.\n", cmd.CommandPath())
+	}
+	if code := exitCode(err); code != ExitOK {
+		stop()
+		os.Exit(code)
 	}
 }
 
@@ -0,0 +1,201 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/controller"
+	"github.com/spf13/cobra"
+)
+
+// Kinds of configured operations
+const (
+	operationAppend    = "append"
+	operationTransform = "transform"
+	operationEdit      = "edit"
+	operationInsert    = "insert"
+)
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run [operation] [filepath] [args...]",
+	Short: "Run an operation defined in the config file",
+	Long: `Run an operation defined under append.operations, transform.operations, edit.operations
+or insert.operations in the config file.
+Append operations write their result into the file, transform operations write a new file,
+edit operations rewrite the file in place after showing a diff and asking for confirmation,
+and insert operations place or update a generated block at an anchor of the file.
+The number of extra arguments is checked against the operation's args settings.
+
+For example:
+  mdai run proofread document.md --yes
+  mdai run translate document.md ja`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.MinimumNArgs(2)(cmd, args); err != nil {
+			return err
+		}
+		_, opConfig, _, err := lookupOperation(config.GetInstance().GetConfig(), args[0])
+		if err != nil {
+			return err
+		}
+		return operationArgs(opConfig.Args)(cmd, args[1:])
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.GetInstance().GetConfig()
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: cfg.Default.GetLogLevel().Level(),
+		}))
+		yes, _ := cmd.Flags().GetBool("yes")
+		if err := runOperation(cmd.Context(), cfg, args[0], args[1:], yes, logger); err != nil {
+			logger.Error("fail in calling run", "error", err)
+			return reported(err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().BoolP("yes", "y", false, "Apply the changes of an edit operation without confirmation")
+}
+
+// addOperationCommands registers a command for every configured operation without a command of its own
+func addOperationCommands(cfg config.Config) {
+	seen := map[string]bool{}
+	var names []string
+	for _, operations := range []map[string]config.OperationConfig{cfg.Append.Operations, cfg.Transform.Operations, cfg.Edit.Operations, cfg.Insert.Operations} {
+		for name := range operations {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if hasCommand(name) {
+			continue
+		}
+		_, opConfig, kind, err := lookupOperation(cfg, name)
+		if err != nil {
+			continue
+		}
+		rootCmd.AddCommand(newOperationCommand(name, kind, opConfig))
+	}
+}
+
+// hasCommand reports whether the name is taken by a built-in command
+func hasCommand(name string) bool {
+	if name == "help" || name == "completion" {
+		return true
+	}
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func newOperationCommand(name, kind string, opConfig config.OperationConfig) *cobra.Command {
+	short := fmt.Sprintf("Run the %s operation defined in the config file", name)
+	long := short
+	if description := strings.TrimSpace(opConfig.Description); description != "" {
+		short, _, _ = strings.Cut(description, "\n")
+		long = description
+	}
+
+	opCmd := &cobra.Command{
+		Use:   name + " [filepath] [args...]",
+		Short: short,
+		Long:  long,
+		Args:  operationArgs(opConfig.Args),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.GetInstance().GetConfig()
+			logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+				Level: cfg.Default.GetLogLevel().Level(),
+			}))
+			yes, _ := cmd.Flags().GetBool("yes")
+			if err := runOperation(cmd.Context(), cfg, name, args, yes, logger); err != nil {
+				logger.Error("fail in calling "+name, "error", err)
+				return reported(err)
+			}
+			return nil
+		},
+	}
+	if kind == operationEdit {
+		opCmd.Flags().BoolP("yes", "y", false, "Apply the changes without confirmation")
+	}
+	return opCmd
+}
+
+// operationArgs validates the file path followed by the extra arguments allowed by the config
+func operationArgs(args config.ArgsConfig) cobra.PositionalArgs {
+	if args.MaxCount > 0 {
+		return cobra.RangeArgs(1+args.MinCount, 1+args.MaxCount)
+	}
+	return cobra.MinimumNArgs(1 + args.MinCount)
+}
+
+// lookupOperation finds the operation in the append, transform, edit and insert operations.
+// The returned configuration carries the model, quality and streaming settings of the operation over the default ones.
+func lookupOperation(cfg config.Config, operation string) (config.Config, config.OperationConfig, string, error) {
+	var (
+		found    config.OperationConfig
+		kind     string
+		sections []string
+	)
+	for _, section := range []struct {
+		kind       string
+		operations map[string]config.OperationConfig
+	}{
+		{operationAppend, cfg.Append.Operations},
+		{operationTransform, cfg.Transform.Operations},
+		{operationEdit, cfg.Edit.Operations},
+		{operationInsert, cfg.Insert.Operations},
+	} {
+		if opConfig, exists := section.operations[operation]; exists {
+			found, kind = opConfig, section.kind
+			sections = append(sections, section.kind)
+		}
+	}
+	switch len(sections) {
+	case 0:
+		return cfg, config.OperationConfig{}, "", fmt.Errorf("unsupported operation: %s", operation)
+	case 1:
+		cfg.Default = cfg.Default.ForOperation(found)
+		return cfg, found, kind, nil
+	}
+	return cfg, config.OperationConfig{}, "", fmt.Errorf("operation %s is defined in more than one of %s", operation, strings.Join(sections, ", "))
+}
+
+func runOperation(ctx context.Context, cfg config.Config, operation string, args []string, yes bool, logger *slog.Logger) error {
+	if len(args) == 0 {
+		return fmt.Errorf("path is required")
+	}
+	_, _, kind, err := lookupOperation(cfg, operation)
+	if err != nil {
+		return err
+	}
+
+	path := args[0]
+	extraArgs := args[1:]
+	switch kind {
+	case operationAppend:
+		return controller.Append(ctx, cfg, operation, path, extraArgs, logger)
+	case operationEdit:
+		return controller.Edit(ctx, cfg, operation, path, extraArgs, yes, os.Stdout, logger)
+	case operationInsert:
+		return controller.Insert(ctx, cfg, operation, path, extraArgs, logger)
+	}
+	return controller.Transform(ctx, cfg, operation, path, extraArgs, logger)
+}
@@ -4,6 +4,7 @@ Copyright © 2025 koooyooo
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -20,14 +21,16 @@ var summarizeCmd = &cobra.Command{
 	Long: `Summarize the content of a markdown file using AI.
 The summarized content will be saved to a new file with "_sum" suffix.
 For example, if the input file is "document.md", the output will be "document_sum.md".`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := config.GetInstance().GetConfig()
 		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 			Level: cfg.Default.GetLogLevel().Level(),
 		}))
-		if err := summarize(cfg, args, logger); err != nil {
+		if err := summarize(cmd.Context(), cfg, args, logger); err != nil {
 			logger.Error("fail in calling summarize", "error", err)
+			return reported(err)
 		}
+		return nil
 	},
 }
 
@@ -35,7 +38,7 @@ func init() {
 	rootCmd.AddCommand(summarizeCmd)
 }
 
-func summarize(cfg config.Config, args []string, logger *slog.Logger) error {
+func summarize(ctx context.Context, cfg config.Config, args []string, logger *slog.Logger) error {
 	if len(args) == 0 {
 		return fmt.Errorf("path is required")
 	}
@@ -44,5 +47,5 @@ func summarize(cfg config.Config, args []string, logger *slog.Logger) error {
 	extraArgs := []string{}
 
 	// Call transform controller directly
-	return controller.Transform(cfg, "summarize", path, extraArgs, logger)
+	return controller.Transform(ctx, cfg, "summarize", path, extraArgs, logger)
 }
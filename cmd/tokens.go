@@ -0,0 +1,109 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/controller"
+	"github.com/koooyooo/mdai/models"
+	"github.com/koooyooo/mdai/tokenizer"
+	"github.com/spf13/cobra"
+)
+
+// tokensCmd represents the tokens command
+var tokensCmd = &cobra.Command{
+	Use:   "tokens [filepath] [args...]",
+	Short: "Count the tokens an operation would send for a markdown file",
+	Long: `Count the tokens an operation would send for a markdown file, without calling the API.
+The prompt is rendered from the operation's templates and counted with the local tokenizer.
+The system and user token counts are printed together with the estimated cost.
+
+For example:
+  mdai tokens document.md
+  mdai tokens document.md --op translate ja`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.GetInstance().GetConfig()
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: cfg.Default.GetLogLevel().Level(),
+		}))
+		operation, _ := cmd.Flags().GetString("op")
+		modelID, _ := cmd.Flags().GetString("model")
+		if err := tokens(cfg, os.Stdout, operation, modelID, args); err != nil {
+			logger.Error("fail in calling tokens", "error", err)
+			return reported(err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tokensCmd)
+	tokensCmd.Flags().String("op", "summarize", "Operation whose prompt is rendered")
+	tokensCmd.Flags().String("model", "", "Model used for counting and pricing (default: the operation's model or default.model)")
+}
+
+func tokens(cfg config.Config, w io.Writer, operation, modelID string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("path is required")
+	}
+	// The model and max_tokens of the operation take precedence over the default ones
+	if opCfg, _, _, err := lookupOperation(cfg, operation); err == nil {
+		cfg = opCfg
+	}
+	if modelID == "" {
+		modelID = cfg.GetModel()
+	}
+
+	sysMsg, messages, err := controller.RenderPrompt(cfg, operation, args[0], args[1:])
+	if err != nil {
+		return err
+	}
+
+	// The last message is the user's; earlier ones are the conversation history
+	sysTokens, exact := tokenizer.Count(modelID, sysMsg)
+	userTokens, _ := tokenizer.Count(modelID, messages[len(messages)-1].Content)
+	historyTokens := 0
+	for _, m := range messages[:len(messages)-1] {
+		n, _ := tokenizer.Count(modelID, m.Content)
+		historyTokens += n
+	}
+	// Each message and the reply priming add a few tokens in the chat format
+	overhead := (1+len(messages))*tokenizer.TokensPerMessage + tokenizer.TokensPerReply
+	total := sysTokens + historyTokens + userTokens + overhead
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "model\t%s\n", modelID)
+	fmt.Fprintf(tw, "encoding\t%s\n", tokenizer.EncodingForModel(modelID))
+	fmt.Fprintf(tw, "operation\t%s\n", operation)
+	fmt.Fprintf(tw, "system tokens\t%d\n", sysTokens)
+	if len(messages) > 1 {
+		fmt.Fprintf(tw, "history tokens\t%d (%d messages)\n", historyTokens, len(messages)-1)
+	}
+	fmt.Fprintf(tw, "user tokens\t%d\n", userTokens)
+	fmt.Fprintf(tw, "prompt tokens\t%d\n", total)
+
+	if model, err := models.GetModelByID(modelID); err == nil {
+		maxTokens := cfg.GetMaxTokens()
+		fmt.Fprintf(tw, "context size\t%d\n", model.ContextSize)
+		fmt.Fprintf(tw, "prompt cost\t$%.5f\n", model.CalculatePromptCost(total))
+		fmt.Fprintf(tw, "max cost\t$%.5f (with %d completion tokens)\n", model.CalculateTotalCost(total, maxTokens), maxTokens)
+	} else {
+		fmt.Fprintf(tw, "prompt cost\tunknown\n")
+	}
+	if encoding := tokenizer.EncodingForModel(modelID); !exact {
+		if _, err := tokenizer.GetEncoding(encoding); errors.Is(err, tokenizer.ErrRanksNotFound) {
+			fmt.Fprintf(tw, "note\tapproximate count (install %s.tiktoken in ~/.mdai/tokenizer for an exact count)\n", encoding)
+		} else {
+			fmt.Fprintf(tw, "note\tapproximate count (non-OpenAI model)\n")
+		}
+	}
+	return tw.Flush()
+}
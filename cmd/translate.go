@@ -4,6 +4,7 @@ Copyright © 2025 koooyooo
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -23,14 +24,16 @@ For example, if the input file is "document.md" and language is "ja",
 the output will be "document_ja.md".
 
 Supported language codes: "en", "ja", "zh", "ko", "es", "fr", "de", etc.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := config.GetInstance().GetConfig()
 		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 			Level: cfg.Default.GetLogLevel().Level(),
 		}))
-		if err := translate(cfg, args, logger); err != nil {
+		if err := translate(cmd.Context(), cfg, args, logger); err != nil {
 			logger.Error("fail in calling translate", "error", err)
+			return reported(err)
 		}
+		return nil
 	},
 }
 
@@ -38,7 +41,7 @@ func init() {
 	rootCmd.AddCommand(translateCmd)
 }
 
-func translate(cfg config.Config, args []string, logger *slog.Logger) error {
+func translate(ctx context.Context, cfg config.Config, args []string, logger *slog.Logger) error {
 	if len(args) < 2 {
 		return fmt.Errorf("both filepath and language are required")
 	}
@@ -48,5 +51,5 @@ func translate(cfg config.Config, args []string, logger *slog.Logger) error {
 	extraArgs := []string{language}
 
 	// Call transform controller directly
-	return controller.Transform(cfg, "translate", path, extraArgs, logger)
+	return controller.Transform(ctx, cfg, "translate", path, extraArgs, logger)
 }
@@ -0,0 +1,86 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/journal"
+	"github.com/spf13/cobra"
+)
+
+// undoCmd represents the undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo [file]",
+	Short: "Restore a file to its state before the last mdai write",
+	Long: `Restore a file to its state before the last mdai write.
+Every write of mdai first records a snapshot of the file in the undo journal (~/.mdai/journal).
+Without a file, the most recent write of any file is undone.
+Running undo again goes one step further back; see mdai history for the recorded snapshots.
+A file edited after the mdai write is left untouched unless --force is given, since the edits would be lost.
+
+For example:
+  mdai undo notes.md
+  mdai undo`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.GetInstance().GetConfig()
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: cfg.Default.GetLogLevel().Level(),
+		}))
+		force, _ := cmd.Flags().GetBool("force")
+		if err := undo(cfg, args, force, logger); err != nil {
+			logger.Error("fail in calling undo", "error", err)
+			return reported(err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+	undoCmd.Flags().Bool("force", false, "Restore the file even if it was modified after the mdai write")
+}
+
+func undo(cfg config.Config, args []string, force bool, logger *slog.Logger) error {
+	j, err := openJournal(cfg)
+	if err != nil {
+		return err
+	}
+
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+	entry, err := j.Undo(path, force)
+	if errors.Is(err, journal.ErrModified) || errors.Is(err, journal.ErrIncomplete) {
+		return fmt.Errorf("%v; run with --force to discard the changes", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if entry.Exists {
+		logger.Info("restored file", "path", entry.Path, "operation", entry.Operation, "snapshot", entry.Timestamp.Format("2006-01-02 15:04:05"))
+	} else {
+		logger.Info("removed file created by mdai", "path", entry.Path, "operation", entry.Operation)
+	}
+	return nil
+}
+
+func openJournal(cfg config.Config) (*journal.Journal, error) {
+	dir := cfg.Journal.Dir
+	if dir == "" {
+		defaultDir, err := journal.DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = defaultDir
+	}
+	return journal.New(dir), nil
+}
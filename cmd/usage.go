@@ -0,0 +1,118 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/usage"
+	"github.com/spf13/cobra"
+)
+
+// usageCmd represents the usage command
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report API usage and cost recorded in the usage ledger",
+	Long: `Report API usage and cost recorded in the usage ledger (~/.mdai/usage.jsonl).
+Every API call is recorded with its timestamp, operation, file, model, token counts and cost.
+The records are aggregated by day, model, operation or file.
+
+For example:
+  mdai usage --by model
+  mdai usage --by file --since 2025-01-01 --format csv`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.GetInstance().GetConfig()
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: cfg.Default.GetLogLevel().Level(),
+		}))
+		by, _ := cmd.Flags().GetString("by")
+		format, _ := cmd.Flags().GetString("format")
+		since, _ := cmd.Flags().GetString("since")
+		until, _ := cmd.Flags().GetString("until")
+		if err := reportUsage(cfg, os.Stdout, by, format, since, until); err != nil {
+			logger.Error("fail in calling usage", "error", err)
+			return reported(err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+	usageCmd.Flags().String("by", "day", "Aggregate by day, model, operation or file")
+	usageCmd.Flags().String("format", "table", "Output format: table, csv or json")
+	usageCmd.Flags().String("since", "", "Include records from this date (YYYY-MM-DD)")
+	usageCmd.Flags().String("until", "", "Include records up to this date (YYYY-MM-DD)")
+}
+
+func reportUsage(cfg config.Config, w io.Writer, by, format, since, until string) error {
+	groupBy, err := usage.ParseGroupBy(by)
+	if err != nil {
+		return err
+	}
+
+	path := cfg.Usage.LedgerPath
+	if path == "" {
+		if path, err = usage.DefaultPath(); err != nil {
+			return err
+		}
+	}
+	records, err := usage.NewLedger(path).Load()
+	if err != nil {
+		return err
+	}
+
+	records, err = filterRecords(records, since, until)
+	if err != nil {
+		return err
+	}
+	summaries := usage.Aggregate(records, groupBy)
+
+	switch format {
+	case "table":
+		return usage.WriteTable(w, groupBy, summaries)
+	case "csv":
+		return usage.WriteCSV(w, groupBy, summaries)
+	case "json":
+		return usage.WriteJSON(w, summaries)
+	default:
+		return fmt.Errorf("unsupported format: %s (table, csv, json)", format)
+	}
+}
+
+func filterRecords(records []usage.Record, since, until string) ([]usage.Record, error) {
+	var from, to time.Time
+	if since != "" {
+		t, err := time.ParseInLocation(time.DateOnly, since, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --since date: %v", err)
+		}
+		from = t
+	}
+	if until != "" {
+		t, err := time.ParseInLocation(time.DateOnly, until, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --until date: %v", err)
+		}
+		// Include the whole day
+		to = t.AddDate(0, 0, 1)
+	}
+
+	filtered := make([]usage.Record, 0, len(records))
+	for _, rec := range records {
+		if !from.IsZero() && rec.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !rec.Timestamp.Before(to) {
+			continue
+		}
+		filtered = append(filtered, rec)
+	}
+	return filtered, nil
+}
@@ -6,7 +6,10 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"text/template"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -17,8 +20,13 @@ type Config struct {
 	Answer    map[string]AnswerConfig `yaml:"answer"` // Legacy
 	Transform TransformConfig         `yaml:"transform"`
 	Append    AppendConfig            `yaml:"append"`
+	Edit      EditConfig              `yaml:"edit"`
+	Insert    InsertConfig            `yaml:"insert"`
 	Summarize SummarizeConfig         `yaml:"summarize"` // Legacy
 	Translate TranslateConfig         `yaml:"translate"` // Legacy
+	Usage     UsageConfig             `yaml:"usage"`
+	Budget    BudgetConfig            `yaml:"budget"`
+	Journal   JournalConfig           `yaml:"journal"`
 }
 
 // DefaultConfig represents the default configuration
@@ -27,6 +35,69 @@ type DefaultConfig struct {
 	Quality       QualityConfig `yaml:"quality"`
 	LogLevel      string        `yaml:"log_level"`
 	DisableStream bool          `yaml:"disable_stream"`
+	// OnCancel selects what happens to a partial answer when a run is interrupted:
+	// "rollback" (default) restores the file, "mark" keeps the partial answer marked as interrupted
+	OnCancel string `yaml:"on_cancel"`
+	// Retry controls how transient API errors are retried
+	Retry RetryConfig `yaml:"retry"`
+	// Provider selects the provider explicitly (openai, anthropic, google).
+	// When empty, the provider is resolved from the model catalog.
+	Provider string `yaml:"provider"`
+	// Connection settings shared by all providers
+	ProviderConfig `yaml:",inline"`
+	// Connection settings per provider, taking precedence over the shared ones
+	Providers ProvidersConfig `yaml:"providers"`
+}
+
+// ProviderConfig represents the connection settings of an AI provider
+type ProviderConfig struct {
+	BaseURL    string            `yaml:"base_url"`
+	Headers    map[string]string `yaml:"headers"`
+	APIVersion string            `yaml:"api_version"`
+	APIKeyEnv  string            `yaml:"api_key_env"`
+}
+
+// ProvidersConfig represents the per-provider connection settings
+type ProvidersConfig struct {
+	OpenAI    ProviderConfig `yaml:"openai"`
+	Anthropic ProviderConfig `yaml:"anthropic"`
+	Google    ProviderConfig `yaml:"google"`
+}
+
+// GetProviderConfig returns the connection settings for the given provider name.
+// Values in the provider section take precedence over the shared default values.
+func (c DefaultConfig) GetProviderConfig(name string) ProviderConfig {
+	var section ProviderConfig
+	switch strings.ToLower(name) {
+	case "openai":
+		section = c.Providers.OpenAI
+	case "anthropic":
+		section = c.Providers.Anthropic
+	case "google":
+		section = c.Providers.Google
+	}
+
+	merged := c.ProviderConfig
+	if section.BaseURL != "" {
+		merged.BaseURL = section.BaseURL
+	}
+	if section.APIVersion != "" {
+		merged.APIVersion = section.APIVersion
+	}
+	if section.APIKeyEnv != "" {
+		merged.APIKeyEnv = section.APIKeyEnv
+	}
+	if len(section.Headers) > 0 {
+		headers := make(map[string]string, len(c.Headers)+len(section.Headers))
+		for k, v := range c.Headers {
+			headers[k] = v
+		}
+		for k, v := range section.Headers {
+			headers[k] = v
+		}
+		merged.Headers = headers
+	}
+	return merged
 }
 
 func (c DefaultConfig) GetLogLevel() slog.Level {
@@ -47,6 +118,51 @@ func (c DefaultConfig) GetLogLevel() slog.Level {
 type QualityConfig struct {
 	MaxTokens   int     `yaml:"max_tokens"`
 	Temperature float64 `yaml:"temperature"`
+	// MaxContinuations is the number of continuation requests sent when a response
+	// is cut off by max_tokens (0 disables continuation)
+	MaxContinuations int `yaml:"max_continuations"`
+}
+
+// UsageConfig represents the usage ledger settings
+type UsageConfig struct {
+	// LedgerPath is the JSON Lines file recording every API call (default: ~/.mdai/usage.jsonl)
+	LedgerPath string `yaml:"ledger_path"`
+	// Disable stops recording API calls to the ledger
+	Disable bool `yaml:"disable"`
+}
+
+// RetryConfig represents the retries of rate limit, server and network errors.
+// Zero values use the defaults; a negative MaxRetries disables retries.
+type RetryConfig struct {
+	MaxRetries     int           `yaml:"max_retries"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// JournalConfig represents the undo journal settings
+type JournalConfig struct {
+	// Dir keeps the snapshots taken before every file write (default: ~/.mdai/journal)
+	Dir string `yaml:"dir"`
+	// Disable stops taking snapshots
+	Disable bool `yaml:"disable"`
+}
+
+// BudgetConfig represents spend caps checked before every API call.
+// A zero value disables the corresponding cap.
+type BudgetConfig struct {
+	// PerCall is the maximum estimated cost of a single call
+	PerCall float64 `yaml:"per_call"`
+	// Daily is the maximum total cost per calendar day, including the ledger total
+	Daily float64 `yaml:"daily"`
+	// Monthly is the maximum total cost per calendar month, including the ledger total
+	Monthly float64 `yaml:"monthly"`
+	// OnExceed selects "refuse" (default) or "confirm" when a cap would be crossed
+	OnExceed string `yaml:"on_exceed"`
+}
+
+// Enabled reports whether any cap is configured
+func (b BudgetConfig) Enabled() bool {
+	return b.PerCall > 0 || b.Daily > 0 || b.Monthly > 0
 }
 
 // TransformConfig represents the configuration for the transform command
@@ -59,6 +175,26 @@ type AppendConfig struct {
 	Operations map[string]OperationConfig `yaml:"operations"`
 }
 
+// EditConfig represents the configuration of operations rewriting a file in place
+type EditConfig struct {
+	Operations map[string]OperationConfig `yaml:"operations"`
+	// BackupSuffix is appended to the file name of the backup of the original (default: ".bak")
+	BackupSuffix string `yaml:"backup_suffix"`
+}
+
+// GetBackupSuffix gets the suffix of backup files
+func (c EditConfig) GetBackupSuffix() string {
+	if c.BackupSuffix == "" {
+		return ".bak"
+	}
+	return c.BackupSuffix
+}
+
+// InsertConfig represents the configuration of operations inserting a generated block at an anchor
+type InsertConfig struct {
+	Operations map[string]OperationConfig `yaml:"operations"`
+}
+
 // AnswerConfig represents the configuration for the answer command
 type AnswerConfig struct {
 	SystemMessage string              `yaml:"system_message"`
@@ -92,6 +228,11 @@ func (t *UserMessageTemplate) Apply(vars map[string]string) (string, error) {
 	return buf.String(), nil
 }
 
+// Uses reports whether the template refers to the variable, e.g. Uses("Question") for {{.Question}}
+func (t *UserMessageTemplate) Uses(name string) bool {
+	return regexp.MustCompile(`\{\{[^}]*\.` + regexp.QuoteMeta(name) + `\b`).MatchString(t.Template)
+}
+
 // ArgsConfig represents argument validation configuration
 type ArgsConfig struct {
 	MinCount int `yaml:"min_count"`
@@ -100,11 +241,116 @@ type ArgsConfig struct {
 
 // OperationConfig represents the configuration for a specific transform operation
 type OperationConfig struct {
+	// Description is the help text of the operation's command; its first line is the short help
+	Description   string              `yaml:"description"`
 	SystemMessage string              `yaml:"system_message"`
 	UserMessage   UserMessageTemplate `yaml:"user_message"`
 	TargetLength  int                 `yaml:"target_length"`
 	Suffix        UserMessageTemplate `yaml:"suffix"`
 	Args          ArgsConfig          `yaml:"args"`
+	Chunking      ChunkingConfig      `yaml:"chunking"`
+	// Insertion selects where an append operation writes its result:
+	// "end" (default) or "after_question"
+	Insertion string `yaml:"insertion"`
+	// Anchor selects where an insert operation places its block
+	Anchor AnchorConfig `yaml:"anchor"`
+	// Model, Quality and DisableStream override the default settings for the operation when set
+	Model string `yaml:"model"`
+	// Provider selects the provider of the operation's model; when empty and the model is
+	// overridden, the provider is resolved from the model catalog instead of default.provider
+	Provider      string          `yaml:"provider"`
+	Quality       QualityOverride `yaml:"quality"`
+	DisableStream *bool           `yaml:"disable_stream"`
+}
+
+// QualityOverride represents the quality settings of an operation; unset fields keep the default values
+type QualityOverride struct {
+	MaxTokens        *int     `yaml:"max_tokens"`
+	Temperature      *float64 `yaml:"temperature"`
+	MaxContinuations *int     `yaml:"max_continuations"`
+}
+
+// ForOperation returns the default settings with the overrides of the operation merged over them
+func (c DefaultConfig) ForOperation(op OperationConfig) DefaultConfig {
+	if op.Model != "" {
+		c.Model = op.Model
+		c.Provider = op.Provider
+	} else if op.Provider != "" {
+		c.Provider = op.Provider
+	}
+	if op.Quality.MaxTokens != nil {
+		c.Quality.MaxTokens = *op.Quality.MaxTokens
+	}
+	if op.Quality.Temperature != nil {
+		c.Quality.Temperature = *op.Quality.Temperature
+	}
+	if op.Quality.MaxContinuations != nil {
+		c.Quality.MaxContinuations = *op.Quality.MaxContinuations
+	}
+	if op.DisableStream != nil {
+		c.DisableStream = *op.DisableStream
+	}
+	return c
+}
+
+// AnchorConfig represents the position of the block of an insert operation
+type AnchorConfig struct {
+	// Position is "top" (default), "after_front_matter", "before_heading", "after_heading" or "marker"
+	Position string `yaml:"position"`
+	// Heading is the regular expression matched against the heading texts (before_heading, after_heading)
+	Heading string `yaml:"heading"`
+	// Marker is the name of the <!-- mdai:marker name="..." --> comment replaced by the block (marker)
+	Marker string `yaml:"marker"`
+}
+
+// Behaviours on cancellation
+const (
+	OnCancelRollback = "rollback"
+	OnCancelMark     = "mark"
+)
+
+// Insertion modes of append operations
+const (
+	InsertionEnd           = "end"
+	InsertionAfterQuestion = "after_question"
+)
+
+// Anchor positions of insert operations
+const (
+	AnchorTop              = "top"
+	AnchorAfterFrontMatter = "after_front_matter"
+	AnchorBeforeHeading    = "before_heading"
+	AnchorAfterHeading     = "after_heading"
+	AnchorMarker           = "marker"
+)
+
+// Chunking strategies
+const (
+	ChunkingMapReduce = "map_reduce"
+	ChunkingSplit     = "split"
+	ChunkingNone      = "none"
+)
+
+// DefaultMaxContinuations is the number of continuation requests when max_continuations is not set
+const DefaultMaxContinuations = 3
+
+// ChunkingConfig represents how documents larger than a single request are processed
+type ChunkingConfig struct {
+	// Strategy selects "map_reduce" (process chunks, then combine the results) or
+	// "split" (process chunks and concatenate the results in order).
+	// "none" sends the whole document in one request. When not set, built-in operations
+	// use their default strategy and other operations send the whole document.
+	Strategy string `yaml:"strategy"`
+	// MaxTokens is the maximum prompt tokens per request.
+	// Default: model context minus max_tokens for map_reduce, half of max_tokens of content for split.
+	MaxTokens int `yaml:"max_tokens"`
+	// ReduceMessage combines the partial results, which are given as {{.Content}}
+	ReduceMessage UserMessageTemplate `yaml:"reduce_message"`
+	// ContextMessage is appended to the user message of each split chunk
+	// ({{.Part}}, {{.Parts}} and {{.Headings}} are available)
+	ContextMessage UserMessageTemplate `yaml:"context_message"`
+	// Concurrency is the maximum number of chunks processed at the same time (default: 4)
+	Concurrency int `yaml:"concurrency"`
 }
 
 // SummarizeConfig represents the configuration for the summarize command
@@ -143,24 +389,65 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	var config Config
+	// Quality settings missing from the file keep their default values, so that an explicit 0 can be told apart
+	config := Config{
+		Default: DefaultConfig{
+			Quality: GetDefaultConfig().Default.Quality,
+		},
+	}
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
+	config.applyDefaultChunking()
 
 	return &config, nil
 }
 
+// applyDefaultChunking sets the default chunking strategy of the built-in operations whose strategy is not set,
+// so that configuration files written before chunking existed process large documents in chunks
+func (c *Config) applyDefaultChunking() {
+	defaults := GetDefaultConfig()
+	for _, pair := range []struct{ operations, defaults map[string]OperationConfig }{
+		{c.Append.Operations, defaults.Append.Operations},
+		{c.Transform.Operations, defaults.Transform.Operations},
+		{c.Edit.Operations, defaults.Edit.Operations},
+		{c.Insert.Operations, defaults.Insert.Operations},
+	} {
+		for name, op := range pair.operations {
+			def, ok := pair.defaults[name]
+			if !ok || op.Chunking.Strategy != "" {
+				continue
+			}
+			op.Chunking.Strategy = def.Chunking.Strategy
+			pair.operations[name] = op
+		}
+	}
+}
+
+// DefaultReduceMessage is the reduce template used when an operation does not define one
+const DefaultReduceMessage = `The following are summaries of consecutive parts of one markdown document:
+
+{{.Content}}
+
+Please combine them into a single well-structured summary of the whole document, removing duplicates while keeping the key points.`
+
+// DefaultChunkContextMessage is the chunk context template used when an operation does not define one
+const DefaultChunkContextMessage = `Note: this is part {{.Part}} of {{.Parts}} of a longer document. Process only this part and output only the result.
+Headings around this part, for consistent terminology:
+{{.Headings}}`
+
 // GetDefaultConfig returns the default configuration
 func GetDefaultConfig() *Config {
 	return &Config{
 		Default: DefaultConfig{
 			Model: "gpt-4o-mini",
 			Quality: QualityConfig{
-				MaxTokens:   2000,
-				Temperature: 0.7,
+				MaxTokens:        2000,
+				Temperature:      0.7,
+				MaxContinuations: DefaultMaxContinuations,
 			},
 			LogLevel:      "info",
+			OnCancel:      OnCancelRollback,
 			DisableStream: false,
 		},
 		Answer: map[string]AnswerConfig{
@@ -184,6 +471,7 @@ Question: {{.Question}}`,
 		Transform: TransformConfig{
 			Operations: map[string]OperationConfig{
 				"summarize": {
+					Description: "Summarize the content of a markdown file",
 					SystemMessage: `You are a helpful and detailed assistant specialized in summarizing markdown documents. When summarizing content, please follow these guidelines:
 
 1. Provide a comprehensive yet concise summary of the main content
@@ -211,8 +499,15 @@ Please create a well-structured summary that captures the essence and key points
 						MinCount: 0,
 						MaxCount: 0,
 					},
+					Chunking: ChunkingConfig{
+						Strategy: ChunkingMapReduce,
+						ReduceMessage: UserMessageTemplate{
+							Template: DefaultReduceMessage,
+						},
+					},
 				},
 				"translate": {
+					Description: "Translate markdown file to specified language",
 					SystemMessage: `You are a professional translator specialized in translating markdown documents. When translating content, please follow these guidelines:
 
 1. Translate the content to the specified target language accurately and naturally
@@ -239,12 +534,20 @@ Please maintain the original markdown formatting and structure while ensuring th
 						MinCount: 1,
 						MaxCount: 1,
 					},
+					Chunking: ChunkingConfig{
+						Strategy: ChunkingSplit,
+						ContextMessage: UserMessageTemplate{
+							Template: DefaultChunkContextMessage,
+						},
+						Concurrency: 4,
+					},
 				},
 			},
 		},
 		Append: AppendConfig{
 			Operations: map[string]OperationConfig{
 				"answer": {
+					Description: "Answer the question based on the content of a markdown file",
 					SystemMessage: `You are a helpful and detailed assistant. When answering questions based on the given context, please follow these guidelines:
 
 1. Answer in the same language as the question
@@ -263,6 +566,73 @@ Question: {{.Question}}`,
 						MinCount: 0,
 						MaxCount: 0,
 					},
+					Insertion: InsertionEnd,
+				},
+			},
+		},
+		Edit: EditConfig{
+			Operations: map[string]OperationConfig{
+				"proofread": {
+					Description: "Proofread a markdown file in place",
+					SystemMessage: `You are a careful proofreader of markdown documents. When proofreading content, please follow these guidelines:
+
+1. Fix spelling, grammar, punctuation and obvious typos
+2. Keep the original language, meaning, tone and wording as much as possible
+3. Preserve the markdown formatting and structure exactly
+4. Do not change code blocks, links, URLs or front matter
+5. Output only the corrected document, without any comments or explanations`,
+					UserMessage: UserMessageTemplate{
+						Template: `Please proofread the following markdown content:
+
+{{.Content}}`,
+					},
+					Args: ArgsConfig{
+						MinCount: 0,
+						MaxCount: 0,
+					},
+					Chunking: ChunkingConfig{
+						Strategy: ChunkingSplit,
+						ContextMessage: UserMessageTemplate{
+							Template: DefaultChunkContextMessage,
+						},
+						Concurrency: 4,
+					},
+				},
+			},
+			BackupSuffix: ".bak",
+		},
+		Insert: InsertConfig{
+			Operations: map[string]OperationConfig{
+				"tldr": {
+					Description: "Insert a TL;DR at the top of a markdown file",
+					SystemMessage: `You are a helpful assistant writing a TL;DR of markdown documents. When writing the TL;DR, please follow these guidelines:
+
+1. Answer in the same language as the document
+2. Summarize the main points in two to four sentences or short bullet points
+3. Start with "**TL;DR**"
+4. Output only the TL;DR, without headings or comments`,
+					UserMessage: UserMessageTemplate{
+						Template: `Please write a TL;DR of the following markdown content:
+
+{{.Content}}`,
+					},
+					Args: ArgsConfig{
+						MinCount: 0,
+						MaxCount: 0,
+					},
+					Chunking: ChunkingConfig{
+						Strategy: ChunkingMapReduce,
+						ReduceMessage: UserMessageTemplate{
+							Template: `The following are TL;DRs of consecutive parts of one markdown document:
+
+{{.Content}}
+
+Please combine them into a single TL;DR of the whole document.`,
+						},
+					},
+					Anchor: AnchorConfig{
+						Position: AnchorTop,
+					},
 				},
 			},
 		},
@@ -4,54 +4,196 @@ Copyright © 2025 koooyooo
 package controller
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/markdown"
+	"github.com/koooyooo/mdai/provider"
 	"github.com/koooyooo/mdai/util/file"
-	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 )
 
 // AppendConfig holds configuration for a specific append operation
 type AppendConfig struct {
+	Operation     string
 	SystemMessage string
 	UserMessage   config.UserMessageTemplate
+	Insertion     string
 	ExtraArgs     []string
 }
 
 // Append performs an append operation on a markdown file
-func Append(cfg config.Config, operation string, path string, extraArgs []string, logger *slog.Logger) error {
+func Append(ctx context.Context, cfg config.Config, operation string, path string, extraArgs []string, logger *slog.Logger) error {
 	// Get operation configuration dynamically
-	opConfig, err := getAppendOperationConfig(cfg, operation)
+	cfg, opConfig, err := getAppendOperationConfig(cfg, operation)
 	if err != nil {
 		return err
 	}
 
+	// Validate arguments using configuration
+	if err := validateArgs(extraArgs, opConfig.Args); err != nil {
+		return err
+	}
+
 	// Create append configuration
 	appendConfig := &AppendConfig{
+		Operation:     operation,
 		SystemMessage: opConfig.SystemMessage,
 		UserMessage:   opConfig.UserMessage,
+		Insertion:     opConfig.Insertion,
 		ExtraArgs:     extraArgs,
 	}
 
 	// Execute append operation
-	return executeAppend(cfg, appendConfig, path, extraArgs, logger)
+	return executeAppend(ctx, cfg, appendConfig, path, extraArgs, logger)
 }
 
-func getAppendOperationConfig(cfg config.Config, operation string) (config.OperationConfig, error) {
-	// Get operation configuration from map
-	opConfig, exists := cfg.Append.Operations[operation]
-	if !exists {
-		return config.OperationConfig{}, fmt.Errorf("unsupported append operation: %s", operation)
+func getAppendOperationConfig(cfg config.Config, operation string) (config.Config, config.OperationConfig, error) {
+	return lookupOperation(cfg, cfg.Append.Operations, "append", operation)
+}
+
+// AppendAll answers every unanswered question of a markdown file in document order.
+// Each question gets the content above it as context, and its answer is inserted right after it.
+func AppendAll(ctx context.Context, cfg config.Config, operation string, path string, logger *slog.Logger) error {
+	cfg, opConfig, err := getAppendOperationConfig(cfg, operation)
+	if err != nil {
+		return err
+	}
+	appendConfig := &AppendConfig{
+		Operation:     operation,
+		SystemMessage: opConfig.SystemMessage,
+		UserMessage:   opConfig.UserMessage,
+		Insertion:     config.InsertionAfterQuestion,
+	}
+
+	if err := validateAppendFile(path); err != nil {
+		return err
+	}
+	content, err := file.LoadContent(path)
+	if err != nil {
+		return fmt.Errorf("fail in loading content: %v", err)
+	}
+	pending := len(unansweredQuestions(content))
+	if pending == 0 {
+		logger.Info("no unanswered question found")
+		return nil
+	}
+
+	aiController, err := newAppendController(cfg, appendConfig, path, logger)
+	if err != nil {
+		return err
 	}
 
-	return opConfig, nil
+	// The file is reloaded after each answer, since the insertions move the following questions.
+	// Each question is answered at most once, even if an answer is not recognized afterwards.
+	for i := 0; i < pending; i++ {
+		content, err := file.LoadContent(path)
+		if err != nil {
+			return fmt.Errorf("fail in loading content: %v", err)
+		}
+		questions := unansweredQuestions(content)
+		if len(questions) == 0 {
+			break
+		}
+		q := questions[0]
+
+		messages, err := threadMessages(appendConfig.UserMessage, content, q, q.Offset, nil)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("answering question", "number", i+1, "of", pending, "turns", len(messages))
+		if err := insertAnswer(ctx, cfg, aiController, appendConfig.SystemMessage, messages, path, content, q.End); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func executeAppend(cfg config.Config, appendConfig *AppendConfig, path string, extraArgs []string, logger *slog.Logger) error {
+// Redo replaces the most recent answer of a markdown file in place with a newly generated one.
+// The most recent answer is the one with the latest time, or the last one in the document.
+func Redo(ctx context.Context, cfg config.Config, operation string, path string, logger *slog.Logger) error {
+	cfg, opConfig, err := getAppendOperationConfig(cfg, operation)
+	if err != nil {
+		return err
+	}
+	appendConfig := &AppendConfig{
+		Operation:     operation,
+		SystemMessage: opConfig.SystemMessage,
+		UserMessage:   opConfig.UserMessage,
+	}
+
+	if err := validateAppendFile(path); err != nil {
+		return err
+	}
+	content, err := file.LoadContent(path)
+	if err != nil {
+		return fmt.Errorf("fail in loading content: %v", err)
+	}
+
+	answer := latestAnswer(file.Answers(content))
+	if answer == nil {
+		return fmt.Errorf("no answer found to redo")
+	}
+
+	// The previous answer is left out of the prompt
+	stripped := content[:answer.Offset] + content[answer.End:]
+	questions := file.Questions(stripped)
+	index := -1
+	for i, q := range questions {
+		if q.Offset < answer.Offset {
+			index = i
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("no question found for the answer to redo")
+	}
+	q := questions[index]
+	end := q.Offset
+	if index == len(questions)-1 {
+		end = len(stripped)
+	}
+	messages, err := threadMessages(appendConfig.UserMessage, stripped, q, end, nil)
+	if err != nil {
+		return err
+	}
+
+	aiController, err := newAppendController(cfg, appendConfig, path, logger)
+	if err != nil {
+		return err
+	}
+	logger.Info("regenerating answer", "question", q.Text, "previousModel", answer.Model, "previousTime", answer.Time)
+	return rewriteAnswer(ctx, cfg, aiController, appendConfig.SystemMessage, messages, path, content[:answer.Offset], content[answer.End:])
+}
+
+func latestAnswer(answers []*file.Answer) *file.Answer {
+	var latest *file.Answer
+	var latestTime time.Time
+	for _, a := range answers {
+		t, _ := time.Parse(time.RFC3339, a.Time)
+		if latest == nil || !t.Before(latestTime) {
+			latest, latestTime = a, t
+		}
+	}
+	return latest
+}
+
+func unansweredQuestions(content string) []file.Question {
+	var questions []file.Question
+	for _, q := range file.Questions(content) {
+		if !q.Answered() {
+			questions = append(questions, q)
+		}
+	}
+	return questions
+}
+
+func executeAppend(ctx context.Context, cfg config.Config, appendConfig *AppendConfig, path string, extraArgs []string, logger *slog.Logger) error {
 	// Validate file
 	if err := validateAppendFile(path); err != nil {
 		return err
@@ -64,38 +206,258 @@ func executeAppend(cfg config.Config, appendConfig *AppendConfig, path string, e
 	}
 
 	// Prepare messages based on operation type
-	sysMsg, userMsg, err := prepareAppendMessages(cfg, appendConfig, content, extraArgs)
+	sysMsg, messages, err := prepareAppendMessages(cfg, appendConfig, content, extraArgs)
 	if err != nil {
 		return err
 	}
 
 	// Execute append operation
-	client := openai.NewClient(option.WithAPIKey(os.Getenv("OPENAI_API_KEY")))
-	openAIController := NewOpenAIController(&client, cfg.Default.Model, logger)
+	aiController, err := newAppendController(cfg, appendConfig, path, logger)
+	if err != nil {
+		return err
+	}
+
+	switch appendConfig.Insertion {
+	case "", config.InsertionEnd:
+		return appendToEnd(ctx, cfg, aiController, sysMsg, messages, path)
+	case config.InsertionAfterQuestion:
+		end, err := file.LastQuestionEnd(content)
+		if err != nil {
+			return fmt.Errorf("fail in locating question: %v", err)
+		}
+		return insertAnswer(ctx, cfg, aiController, sysMsg, messages, path, content, end)
+	default:
+		return fmt.Errorf("unsupported insertion mode: %s", appendConfig.Insertion)
+	}
+}
+
+func newAppendController(cfg config.Config, appendConfig *AppendConfig, path string, logger *slog.Logger) (*AIController, error) {
+	p, err := newProvider(cfg.Default)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := newLedger(cfg)
+	if err != nil {
+		return nil, err
+	}
+	aiController := NewAIController(p, cfg.Default.Model, logger).WithBudget(cfg.Budget).WithRetry(cfg.Default.Retry).WithLedger(ledger, appendConfig.Operation, path)
 
-	// Log configuration values
-	logger.Info("using configuration",
-		"maxTokens", cfg.Default.Quality.MaxTokens,
-		"temperature", cfg.Default.Quality.Temperature)
+	logConfiguration(logger, appendConfig.Operation, cfg.Default, "stream", !cfg.Default.DisableStream)
+	return aiController, nil
+}
 
+// appendToEnd writes the answer between the answer markers at the end of the file
+func appendToEnd(ctx context.Context, cfg config.Config, aiController *AIController, sysMsg string, messages []provider.Message, path string) error {
 	// Open file for appending
 	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %v", err)
 	}
 	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	// The opening marker is written with the first content so that a refused call leaves the file untouched
+	w := &answerWriter{w: f, model: aiController.Model()}
+	var written, discard func() error
+	genErr := generate(ctx, cfg, aiController, sysMsg, messages, func(s string) error {
+		if !w.opened {
+			var err error
+			if written, discard, err = snapshot(cfg, path, aiController.operation); err != nil {
+				return err
+			}
+			if err := w.open("\n\n"); err != nil {
+				return fmt.Errorf("failed to write newlines: %v", err)
+			}
+		}
+		return w.write(s)
+	})
+	if genErr != nil && w.opened {
+		if ctx.Err() != nil && cfg.Default.OnCancel == config.OnCancelMark {
+			if err := w.interrupt(); err != nil {
+				return interrupted(ctx, err)
+			}
+			return interrupted(ctx, written())
+		}
+		// The partial answer is removed, as rewriteAnswer leaves the file untouched on a failure
+		if err := f.Truncate(info.Size()); err != nil {
+			if ctx.Err() != nil {
+				return interrupted(ctx, err)
+			}
+			return fmt.Errorf("%v, and failed to clean up the file: %v", genErr, err)
+		}
+		if ctx.Err() != nil {
+			return interrupted(ctx, discard())
+		}
+		_ = discard()
+	}
+	if genErr != nil {
+		return genErr
+	}
+	if !w.opened {
+		return nil
+	}
+	if err := w.close(aiController.LastCost()); err != nil {
+		_ = f.Truncate(info.Size())
+		_ = discard()
+		return fmt.Errorf("failed to write marker: %v", err)
+	}
+	if err := written(); err != nil {
+		return err
+	}
+	return nil
+}
 
-	if _, err := f.WriteString("\n\n"); err != nil {
-		return fmt.Errorf("failed to write newlines: %v", err)
+// insertAnswer writes the answer between the answer markers at the offset, usually right after the question
+func insertAnswer(ctx context.Context, cfg config.Config, aiController *AIController, sysMsg string, messages []provider.Message, path, content string, offset int) error {
+	head, tail := content[:offset], content[offset:]
+	if !strings.HasSuffix(head, "\n") {
+		head += "\n"
 	}
+	// Keep a blank line before and after the answer
+	head += "\n"
+	if tail != "" && !strings.HasPrefix(tail, "\n") {
+		tail = "\n" + tail
+	}
+	return rewriteAnswer(ctx, cfg, aiController, sysMsg, messages, path, head, tail)
+}
+
+// rewriteAnswer writes the file as head, the answer between the answer markers, and tail.
+// The file is rebuilt in a temporary file and replaced only when the answer is complete.
+func rewriteAnswer(ctx context.Context, cfg config.Config, aiController *AIController, sysMsg string, messages []provider.Message, path, head, tail string) error {
+	f, err := file.NewAtomicWriter(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Abort() }()
 
+	w := &answerWriter{w: f, model: aiController.Model()}
+	if err := w.open(head); err != nil {
+		return fmt.Errorf("failed to write content: %v", err)
+	}
+	genErr := generate(ctx, cfg, aiController, sysMsg, messages, w.write)
+	if genErr != nil {
+		if ctx.Err() == nil {
+			return genErr
+		}
+		// The original file stays untouched unless the partial answer is kept
+		if cfg.Default.OnCancel != config.OnCancelMark {
+			return interrupted(ctx, nil)
+		}
+		if err := w.interrupt(); err != nil {
+			return interrupted(ctx, err)
+		}
+	} else if err := w.close(aiController.LastCost()); err != nil {
+		return fmt.Errorf("failed to write marker: %v", err)
+	}
+	if _, err := f.WriteString(tail); err != nil {
+		return fmt.Errorf("failed to write content: %v", err)
+	}
+	written, discard, err := snapshot(cfg, path, aiController.operation)
+	if err != nil {
+		return err
+	}
+	if err := f.Commit(); err != nil {
+		_ = discard()
+		return err
+	}
+	if err := written(); err != nil {
+		return err
+	}
+	if genErr != nil {
+		return interrupted(ctx, nil)
+	}
+	return nil
+}
+
+// interrupted returns the cancellation error of the context, if any, reporting a failure to clean up the file
+func interrupted(ctx context.Context, cleanupErr error) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	if cleanupErr != nil {
+		return fmt.Errorf("answer interrupted (%w), and failed to clean up the file: %v", ctx.Err(), cleanupErr)
+	}
+	return fmt.Errorf("answer interrupted: %w", ctx.Err())
+}
+
+// answerWriter writes an answer between the answer markers
+type answerWriter struct {
+	w      io.StringWriter
+	model  string
+	opened bool
+	// last is the last byte written, used to put the closing marker on a line of its own
+	last byte
+	// line is the incomplete last line, and fence follows the code blocks of the complete ones
+	line  string
+	fence markdown.Fence
+}
+
+func (a *answerWriter) open(prefix string) error {
+	a.opened = true
+	_, err := a.w.WriteString(prefix + file.AnswerOpenMarker(a.model, time.Now()) + "\n")
+	return err
+}
+
+func (a *answerWriter) write(s string) error {
+	if s == "" {
+		return nil
+	}
+	a.last = s[len(s)-1]
+	lines := strings.Split(a.line+s, "\n")
+	for _, l := range lines[:len(lines)-1] {
+		a.fence.Next(l)
+	}
+	a.line = lines[len(lines)-1]
+	_, err := a.w.WriteString(s)
+	return err
+}
+
+// end completes the last line of the answer and closes a code block left open,
+// so that what follows is not part of the code block
+func (a *answerWriter) end() error {
+	if a.last != 0 && a.last != '\n' {
+		if err := a.write("\n"); err != nil {
+			return err
+		}
+	}
+	if closing := a.fence.Closing(); closing != "" {
+		return a.write(closing + "\n")
+	}
+	return nil
+}
+
+// interrupt closes a partial answer with a note that it was interrupted
+func (a *answerWriter) interrupt() error {
+	if err := a.end(); err != nil {
+		return err
+	}
+	if err := a.write("\n" + file.InterruptedNote + "\n"); err != nil {
+		return err
+	}
+	return a.close("")
+}
+
+// close writes the closing marker after a blank line, which also ends an HTML block of the answer
+func (a *answerWriter) close(cost string) error {
+	if err := a.end(); err != nil {
+		return err
+	}
+	_, err := a.w.WriteString("\n" + file.AnswerCloseMarker(cost) + "\n")
+	return err
+}
+
+// generate runs the request and passes the answer to write, streamed unless disabled
+func generate(ctx context.Context, cfg config.Config, aiController *AIController, sysMsg string, messages []provider.Message, write func(string) error) error {
 	// Check if streaming should be disabled
 	nonStream := false // This could be passed as a parameter or from config
 	if nonStream || cfg.Default.DisableStream {
 		// Non-streaming mode with cost calculation
-		return openAIController.Control(sysMsg, userMsg, cfg.Default.Quality, func(completion *openai.ChatCompletion) error {
-			answer := completion.Choices[0].Message.Content
-			if _, err := f.WriteString(answer); err != nil {
+		return aiController.ControlMessages(ctx, sysMsg, messages, cfg.Default.Quality, func(res *provider.Response) error {
+			answer := res.Content
+			if err := write(answer); err != nil {
 				return fmt.Errorf("failed to write answer: %v", err)
 			}
 			return nil
@@ -103,9 +465,8 @@ func executeAppend(cfg config.Config, appendConfig *AppendConfig, path string, e
 	}
 
 	// Streaming mode
-	return openAIController.ControlStreaming(sysMsg, userMsg, cfg.Default.Quality, func(chunk openai.ChatCompletionChunk) error {
-		answer := chunk.Choices[0].Delta.Content
-		if _, err := f.WriteString(answer); err != nil {
+	return aiController.ControlStreamingMessages(ctx, sysMsg, messages, cfg.Default.Quality, func(delta string) error {
+		if err := write(delta); err != nil {
 			return fmt.Errorf("failed to write chunk: %v", err)
 		}
 		return nil
@@ -126,30 +487,62 @@ func validateAppendFile(path string) error {
 	return nil
 }
 
-func prepareAppendMessages(cfg config.Config, appendConfig *AppendConfig, content string, extraArgs []string) (string, string, error) {
+func prepareAppendMessages(cfg config.Config, appendConfig *AppendConfig, content string, extraArgs []string) (string, []provider.Message, error) {
 	sysMsg := appendConfig.SystemMessage
 
-	// Prepare template variables
-	templateVars := map[string]string{
+	// Operations asking about a question, like answer, build the conversation up to the last quote
+	if appendConfig.UserMessage.Uses("Question") {
+		questions := file.Questions(content)
+		if len(questions) == 0 {
+			return "", nil, fmt.Errorf("fail in loading last quote: no quote (line starting with >) found")
+		}
+		messages, err := threadMessages(appendConfig.UserMessage, content, questions[len(questions)-1], len(content), extraArgs)
+		if err != nil {
+			return "", nil, err
+		}
+		return sysMsg, messages, nil
+	}
+
+	// Apply template processing
+	userMsg, err := appendConfig.UserMessage.Apply(argTemplateVars(map[string]string{
 		"Content": content,
+	}, extraArgs))
+	if err != nil {
+		return "", nil, fmt.Errorf("fail in creating user message: %v", err)
 	}
 
-	// Add operation-specific template variables based on extraArgs
-	// For answer operation, extract last quote and other content
-	if len(extraArgs) == 0 { // answer operation doesn't have extra args
-		lastQuote, otherContents, err := file.LoadLastQuote(content)
+	return sysMsg, userMessages(userMsg), nil
+}
+
+// threadMessages builds the chat history of the question: earlier answered questions
+// as user turns and their answers as assistant turns, followed by the question itself.
+// The context of each turn is the content written since the previous answer, up to end for the last one.
+func threadMessages(tmpl config.UserMessageTemplate, content string, q file.Question, end int, extraArgs []string) ([]provider.Message, error) {
+	turns, questionContext := file.LoadThread(content, q, end)
+
+	var messages []provider.Message
+	for _, turn := range turns {
+		userMsg, err := tmpl.Apply(argTemplateVars(map[string]string{
+			"Content":  turn.Context,
+			"Question": turn.Question,
+			"Context":  turn.Context,
+		}, extraArgs))
 		if err != nil {
-			return "", "", fmt.Errorf("fail in loading last quote: %v", err)
+			return nil, fmt.Errorf("fail in creating user message: %v", err)
 		}
-		templateVars["Question"] = lastQuote
-		templateVars["Context"] = otherContents
+		messages = append(messages,
+			provider.Message{Role: provider.RoleUser, Content: userMsg},
+			provider.Message{Role: provider.RoleAssistant, Content: turn.Answer},
+		)
 	}
 
-	// Apply template processing
-	userMsg, err := appendConfig.UserMessage.Apply(templateVars)
+	userMsg, err := tmpl.Apply(argTemplateVars(map[string]string{
+		"Content":  content,
+		"Question": q.Text,
+		"Context":  questionContext,
+	}, extraArgs))
 	if err != nil {
-		return "", "", fmt.Errorf("fail in creating user message: %v", err)
+		return nil, fmt.Errorf("fail in creating user message: %v", err)
 	}
-
-	return sysMsg, userMsg, nil
+	return append(messages, provider.Message{Role: provider.RoleUser, Content: userMsg}), nil
 }
@@ -0,0 +1,119 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/koooyooo/mdai/models"
+	"github.com/koooyooo/mdai/provider"
+	"github.com/koooyooo/mdai/tokenizer"
+	"github.com/koooyooo/mdai/usage"
+	"github.com/koooyooo/mdai/util/prompt"
+)
+
+// ErrBudgetExceeded is returned when a call would cross a configured spend cap
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// confirm asks the user whether to continue. It is a variable so that tests can replace it.
+var confirm = prompt.Confirm
+
+// noRelease is returned by checkBudget when nothing was reserved
+func noRelease() {}
+
+// checkBudget estimates the cost of the request and refuses it (or asks for confirmation)
+// when the estimate or the accumulated ledger total would cross a cap.
+// Checks of concurrent calls are serialised, and the estimate of an accepted call stays reserved
+// until the returned release is called, once the actual cost is in the ledger.
+func (c *AIController) checkBudget(req provider.Request) (func(), error) {
+	if !c.budget.Enabled() {
+		return noRelease, nil
+	}
+
+	model, err := models.GetModelByID(c.modelID)
+	if err != nil {
+		c.logger.Warn("budget check skipped: model pricing is unknown", "model", c.modelID)
+		return noRelease, nil
+	}
+
+	c.budgetMu.Lock()
+	defer c.budgetMu.Unlock()
+
+	// The completion is estimated at its upper bound so that a cap is never crossed silently
+	promptTokens := estimateRequestTokens(c.modelID, req)
+	estimate := model.CalculateTotalCost(promptTokens, req.MaxTokens)
+	c.logger.Info("estimated cost",
+		"model", c.modelID,
+		"promptTokens", promptTokens,
+		"maxCompletionTokens", req.MaxTokens,
+		"estimate", fmt.Sprintf("$%.5f", estimate))
+
+	var violations []string
+	if c.budget.PerCall > 0 && estimate > c.budget.PerCall {
+		violations = append(violations, fmt.Sprintf("estimated cost $%.5f exceeds per-call cap $%.5f", estimate, c.budget.PerCall))
+	}
+
+	if c.budget.Daily > 0 || c.budget.Monthly > 0 {
+		if c.ledger == nil {
+			c.logger.Warn("daily and monthly caps are not checked because the usage ledger is disabled")
+		} else {
+			records, err := c.ledger.Load()
+			if err != nil {
+				return nil, fmt.Errorf("fail in loading usage ledger: %v", err)
+			}
+			now := time.Now()
+			dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+			monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+			// Calls in flight are not in the ledger yet, so their estimates count instead
+			if daily := usage.SumCost(records, dayStart) + c.reserved; c.budget.Daily > 0 && daily+estimate > c.budget.Daily {
+				violations = append(violations, fmt.Sprintf("today's total $%.5f + estimate $%.5f exceeds daily cap $%.5f", daily, estimate, c.budget.Daily))
+			}
+			if monthly := usage.SumCost(records, monthStart) + c.reserved; c.budget.Monthly > 0 && monthly+estimate > c.budget.Monthly {
+				violations = append(violations, fmt.Sprintf("this month's total $%.5f + estimate $%.5f exceeds monthly cap $%.5f", monthly, estimate, c.budget.Monthly))
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		if c.budget.OnExceed != "confirm" {
+			return nil, fmt.Errorf("%w: %s", ErrBudgetExceeded, strings.Join(violations, "; "))
+		}
+		for _, v := range violations {
+			c.logger.Warn("budget warning", "reason", v)
+		}
+		ok, err := confirm("The call would exceed the budget. Continue?")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrBudgetExceeded, strings.Join(violations, "; "))
+		}
+	}
+
+	c.reserved += estimate
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.budgetMu.Lock()
+			defer c.budgetMu.Unlock()
+			c.reserved -= estimate
+		})
+	}, nil
+}
+
+// estimateRequestTokens counts the prompt tokens of a request with the local tokenizer
+func estimateRequestTokens(modelID string, req provider.Request) int {
+	tokens, _ := tokenizer.Count(modelID, req.System)
+	tokens += tokenizer.TokensPerMessage + tokenizer.TokensPerReply
+	for _, m := range req.Messages {
+		n, _ := tokenizer.Count(modelID, m.Content)
+		tokens += n + tokenizer.TokensPerMessage
+	}
+	return tokens
+}
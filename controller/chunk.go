@@ -0,0 +1,100 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"strings"
+
+	"github.com/koooyooo/mdai/markdown"
+)
+
+// splitChunks splits markdown content into chunks of at most maxTokens tokens.
+// Chunks break at heading boundaries first, then at blank lines, then at line ends,
+// so that concatenating the chunks reproduces the content.
+// A fenced code block is never broken: one larger than maxTokens forms a chunk of its own.
+func splitChunks(content string, maxTokens int, count func(string) int) []string {
+	splitters := []func(string) []string{splitSections, splitParagraphs, splitLines, splitRunes}
+	return packUnits([]string{content}, maxTokens, count, splitters)
+}
+
+// packUnits greedily merges units into chunks, breaking oversized units with the next splitter
+func packUnits(units []string, maxTokens int, count func(string) int, splitters []func(string) []string) []string {
+	var chunks []string
+	var current strings.Builder
+	currentTokens := 0
+
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			currentTokens = 0
+		}
+	}
+
+	for _, unit := range units {
+		tokens := count(unit)
+		if tokens > maxTokens && len(splitters) > 0 {
+			parts := splitters[0](unit)
+			if len(parts) > 1 || len(splitters) > 1 {
+				flush()
+				chunks = append(chunks, packUnits(parts, maxTokens, count, splitters[1:])...)
+				continue
+			}
+		}
+		if currentTokens > 0 && currentTokens+tokens > maxTokens {
+			flush()
+		}
+		current.WriteString(unit)
+		currentTokens += tokens
+	}
+	flush()
+	return chunks
+}
+
+// splitSections splits content before each heading, keeping the front matter apart
+func splitSections(content string) []string {
+	return markdown.Parse(content).Sections()
+}
+
+// splitParagraphs splits content between top-level blocks separated by blank lines
+func splitParagraphs(content string) []string {
+	return markdown.Parse(content).Paragraphs()
+}
+
+// splitLines splits content after each line, keeping every fenced code block in one piece
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var pieces []string
+	var fence markdown.Fence
+	for _, line := range lines {
+		inFence := fence.Closing() != ""
+		fence.Next(strings.TrimRight(line, "\r\n"))
+		if inFence {
+			pieces[len(pieces)-1] += line
+			continue
+		}
+		pieces = append(pieces, line)
+	}
+	return pieces
+}
+
+// splitRunes splits an overlong line into pieces of a fixed number of characters; fenced code blocks are kept whole
+func splitRunes(content string) []string {
+	firstLine, _, _ := strings.Cut(content, "\n")
+	var fence markdown.Fence
+	if fence.Next(strings.TrimRight(firstLine, "\r")) {
+		return []string{content}
+	}
+	const size = 1000
+	runes := []rune(content)
+	var pieces []string
+	for len(runes) > size {
+		pieces = append(pieces, string(runes[:size]))
+		runes = runes[size:]
+	}
+	return append(pieces, string(runes))
+}
@@ -0,0 +1,127 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/markdown"
+	"github.com/koooyooo/mdai/models"
+	"github.com/koooyooo/mdai/provider"
+)
+
+// chunkedDocument has a tight list and a fenced code block that are each larger than a small budget
+func chunkedDocument() string {
+	var b strings.Builder
+	b.WriteString("# Title\n\nIntro paragraph.\n\n")
+	for i := 0; i < 6; i++ {
+		b.WriteString("- a list item that takes a few tokens\n")
+	}
+	b.WriteString("\n```go\n")
+	for i := 0; i < 6; i++ {
+		b.WriteString("fmt.Println(\"a line of code\")\n")
+	}
+	b.WriteString("```\n\n## Next\n\nClosing text.\n")
+	return b.String()
+}
+
+func countRunes(s string) int {
+	return utf8.RuneCountInString(s) / 4
+}
+
+// openFence returns the fence a chunk leaves open, or an empty string
+func openFence(chunk string) string {
+	var fence markdown.Fence
+	for _, line := range strings.Split(chunk, "\n") {
+		fence.Next(line)
+	}
+	return fence.Closing()
+}
+
+func TestSplitChunks(t *testing.T) {
+	content := chunkedDocument()
+	chunks := splitChunks(content, 20, countRunes)
+	if got := strings.Join(chunks, ""); got != content {
+		t.Fatalf("chunks do not reproduce the content:\n%q", got)
+	}
+	if len(chunks) < 4 {
+		t.Errorf("chunks = %d, want the list and the sections split", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if fence := openFence(chunk); fence != "" {
+			t.Errorf("chunk %d leaves the fence %q open:\n%s", i, fence, chunk)
+		}
+	}
+}
+
+func TestSplitLinesKeepsFences(t *testing.T) {
+	got := splitLines("a\n```\nb\n\nc\n```\nd\n")
+	want := []string{"a\n", "```\nb\n\nc\n```\n", "d\n"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("splitLines() = %q, want %q", got, want)
+	}
+	if pieces := splitRunes("```\n" + strings.Repeat("x", 3000) + "\n```\n"); len(pieces) != 1 {
+		t.Errorf("splitRunes() broke a fenced block into %d pieces", len(pieces))
+	}
+}
+
+// echoProvider returns the chunk of the request, surrounded by the whitespace models tend to add
+type echoProvider struct{}
+
+func (echoProvider) Name() models.Provider {
+	return models.ProviderOpenAI
+}
+
+func (echoProvider) Complete(ctx context.Context, req provider.Request) (*provider.Response, error) {
+	content := req.Messages[len(req.Messages)-1].Content
+	chunk, _, _ := strings.Cut(strings.TrimPrefix(content, "<<"), ">>")
+	return &provider.Response{Content: "\n" + strings.TrimSpace(chunk) + "\n\n\n", FinishReason: provider.FinishReasonStop}, nil
+}
+
+func (p echoProvider) Stream(ctx context.Context, req provider.Request, deltaFunc func(delta string) error) (*provider.Response, error) {
+	return p.Complete(ctx, req)
+}
+
+func TestSplitTransformKeepsSeparators(t *testing.T) {
+	content := chunkedDocument()
+	transformConfig := &TransformConfig{
+		Operation:   "echo",
+		UserMessage: config.UserMessageTemplate{Template: "<<{{.Content}}>>"},
+		Chunking: config.ChunkingConfig{
+			Strategy:       config.ChunkingSplit,
+			ContextMessage: config.UserMessageTemplate{Template: "part {{.Part}}"},
+			Concurrency:    2,
+		},
+	}
+	c := NewAIController(echoProvider{}, "gpt-4o-mini", testLogger())
+	got, err := splitTransform(context.Background(), c, testConfig(), transformConfig, content, map[string]string{}, 20, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != content {
+		t.Errorf("splitTransform() =\n%s\nwant\n%s", got, content)
+	}
+}
+
+func TestTextBounds(t *testing.T) {
+	tests := []struct {
+		s    string
+		text string
+	}{
+		{"\n\n  text\n  more  \n\n", "  text\n  more"},
+		{"text", "text"},
+		{" \n\t\n", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		start, end := textBounds(tt.s)
+		if got := tt.s[start:end]; got != tt.text {
+			t.Errorf("textBounds(%q) gives %q, want %q", tt.s, got, tt.text)
+		}
+	}
+}
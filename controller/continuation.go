@@ -0,0 +1,130 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/provider"
+)
+
+// continueMessage asks the model to go on after a response cut off by max_tokens
+const continueMessage = "Your previous response was cut off. Continue exactly where it stopped, without repeating any text and without any preamble."
+
+const (
+	// maxOverlap is the length of the text at the start of a continuation checked for repetition
+	maxOverlap = 200
+	// minOverlap is the shortest repetition that is trimmed, so that coincidental matches are kept
+	minOverlap = 16
+)
+
+// shouldContinue reports whether the response was cut off by max_tokens and another round is allowed
+func (c *AIController) shouldContinue(res *provider.Response, round int, quality config.QualityConfig) bool {
+	if res.FinishReason != provider.FinishReasonLength {
+		return false
+	}
+	if round >= quality.MaxContinuations {
+		if quality.MaxContinuations > 0 {
+			c.logger.Warn("response truncated by max_tokens, continuation limit reached", "continuations", round)
+		} else {
+			c.logger.Warn("response truncated by max_tokens")
+		}
+		return false
+	}
+	c.logger.Info("response truncated by max_tokens, continuing", "round", round+1, "of", quality.MaxContinuations)
+	return true
+}
+
+// continuationRequest returns the request with the partial response as the assistant's turn,
+// followed by a request to continue it
+func continuationRequest(req provider.Request, partial string) provider.Request {
+	messages := make([]provider.Message, 0, len(req.Messages)+2)
+	messages = append(messages, req.Messages...)
+	messages = append(messages,
+		provider.Message{Role: provider.RoleAssistant, Content: partial},
+		provider.Message{Role: provider.RoleUser, Content: continueMessage},
+	)
+	req.Messages = messages
+	return req
+}
+
+// trimOverlap returns next without the text it repeats from the end of prev
+func trimOverlap(prev, next string) string {
+	limit := min(len(next), len(prev), maxOverlap)
+	for n := limit; n >= minOverlap; n-- {
+		if strings.HasSuffix(prev, next[:n]) {
+			return next[n:]
+		}
+	}
+	return next
+}
+
+// overlapTrimmer holds back the beginning of a streamed continuation until
+// the text it repeats from prev can be trimmed
+type overlapTrimmer struct {
+	prev string
+	buf  strings.Builder
+	done bool
+	out  func(string) error
+}
+
+func (t *overlapTrimmer) write(delta string) error {
+	if t.done {
+		return t.out(delta)
+	}
+	t.buf.WriteString(delta)
+	if t.buf.Len() < maxOverlap {
+		return nil
+	}
+	return t.flush()
+}
+
+// flush writes the held back text; it must be called when the stream ends
+func (t *overlapTrimmer) flush() error {
+	if t.done {
+		return nil
+	}
+	t.done = true
+	if trimmed := trimOverlap(t.prev, t.buf.String()); trimmed != "" {
+		return t.out(trimmed)
+	}
+	return nil
+}
+
+// callCost sums the cost of the rounds of a call; it is unknown when any round is
+type callCost struct {
+	total   float64
+	unknown bool
+}
+
+func (c *callCost) add(cost *float64, err error) error {
+	if err != nil {
+		return err
+	}
+	if cost == nil {
+		c.unknown = true
+		return nil
+	}
+	c.total += *cost
+	return nil
+}
+
+func (c callCost) String() string {
+	if c.unknown {
+		return ""
+	}
+	return fmt.Sprintf("$%.5f", c.total)
+}
+
+func addUsage(a, b *provider.Usage) *provider.Usage {
+	if a == nil || b == nil {
+		return nil
+	}
+	return &provider.Usage{
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/provider"
+)
+
+// testController retries quickly, so that the retries do not slow down the tests
+func testController(p provider.Provider) *AIController {
+	return NewAIController(p, "gpt-4o-mini", testLogger()).WithRetry(config.RetryConfig{
+		MaxRetries:     2,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	})
+}
+
+func TestTrimOverlap(t *testing.T) {
+	tests := []struct {
+		name string
+		prev string
+		next string
+		want string
+	}{
+		{"no overlap", "The first part.", " The second part.", " The second part."},
+		{"repeated tail", "The answer ends with a sentence that", "ends with a sentence that is cut off.", " is cut off."},
+		{"short coincidental overlap kept", "It is a cat", "a cat sat down", "a cat sat down"},
+		{"whole repetition", "abcdefghijklmnopqrstuvwxyz", "klmnopqrstuvwxyz", ""},
+		{"empty prev", "", "next", "next"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimOverlap(tt.prev, tt.next); got != tt.want {
+				t.Errorf("trimOverlap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestControlStreamingContinuation(t *testing.T) {
+	p := &fakeProvider{responses: []fakeResponse{
+		{content: "The answer starts here and ends with a sentence that", finish: provider.FinishReasonLength},
+		{content: "ends with a sentence that is continued.", finish: provider.FinishReasonStop},
+	}}
+	var out strings.Builder
+	err := testController(p).ControlStreaming(context.Background(), "sys", "question", config.QualityConfig{MaxContinuations: 1}, func(delta string) error {
+		out.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "The answer starts here and ends with a sentence that is continued."; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	if len(p.requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(p.requests))
+	}
+	messages := p.requests[1].Messages
+	if len(messages) != 3 || messages[1].Role != provider.RoleAssistant || messages[2].Content != continueMessage {
+		t.Errorf("continuation messages = %+v", messages)
+	}
+}
@@ -7,125 +7,355 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/journal"
 	"github.com/koooyooo/mdai/models"
-	"github.com/openai/openai-go"
+	"github.com/koooyooo/mdai/provider"
+	"github.com/koooyooo/mdai/usage"
 )
 
-type OpenAIController struct {
-	client  *openai.Client
-	modelID string
-	logger  *slog.Logger
+// newProvider resolves the provider for the default configuration.
+// It is a variable so that tests can inject a fake provider.
+var newProvider = func(def config.DefaultConfig) (provider.Provider, error) {
+	name, err := resolveProvider(def)
+	if err != nil {
+		return nil, err
+	}
+
+	pc := def.GetProviderConfig(string(name))
+	return provider.New(name, provider.Options{
+		BaseURL:    pc.BaseURL,
+		Headers:    pc.Headers,
+		APIVersion: pc.APIVersion,
+		APIKeyEnv:  pc.APIKeyEnv,
+	})
 }
 
-func NewOpenAIController(client *openai.Client, modelID string, logger *slog.Logger) *OpenAIController {
-	return &OpenAIController{
-		client:  client,
-		modelID: modelID,
-		logger:  logger,
+// resolveProvider returns the explicitly configured provider, or the provider of the model.
+// Models missing from the catalog are sent to the OpenAI-compatible endpoint.
+func resolveProvider(def config.DefaultConfig) (models.Provider, error) {
+	if def.Provider != "" {
+		return models.ParseProvider(def.Provider)
+	}
+	if model, err := models.GetModelByID(def.Model); err == nil {
+		return model.Provider, nil
 	}
+	return models.ProviderOpenAI, nil
 }
 
-func (c *OpenAIController) Control(sysMsg, usrMsg string, quality config.QualityConfig, completionFunc func(res *openai.ChatCompletion) error) error {
-	// Use default values if configuration values are 0
-	maxTokens := quality.MaxTokens
-	temperature := quality.Temperature
-	if maxTokens == 0 {
-		maxTokens = models.DefaultMaxTokens
+// newLedger returns the usage ledger, or nil when recording is disabled
+func newLedger(cfg config.Config) (*usage.Ledger, error) {
+	if cfg.Usage.Disable {
+		return nil, nil
 	}
-	if temperature == 0.0 {
-		temperature = models.DefaultTemperature
+	path := cfg.Usage.LedgerPath
+	if path == "" {
+		defaultPath, err := usage.DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+		path = defaultPath
 	}
+	return usage.NewLedger(path), nil
+}
 
-	completion, err := c.client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
-		Model: c.modelID,
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage(sysMsg),
-			openai.UserMessage(usrMsg),
-		},
-		MaxTokens:   openai.Int(int64(maxTokens)),
-		Temperature: openai.Float(temperature),
-	})
-	if err != nil {
-		return fmt.Errorf("OpenAI API error: %v", err)
+// newJournal returns the undo journal, or nil when snapshots are disabled
+func newJournal(cfg config.Config) (*journal.Journal, error) {
+	if cfg.Journal.Disable {
+		return nil, nil
 	}
-	if len(completion.Choices) == 0 {
-		return fmt.Errorf("no response from OpenAI API")
+	dir := cfg.Journal.Dir
+	if dir == "" {
+		defaultDir, err := journal.DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = defaultDir
 	}
+	return journal.New(dir), nil
+}
 
-	costInfo, err := models.CalculateCostString(c.modelID, completion.Usage)
+// snapshot records the current content of the file in the undo journal before it is written.
+// The returned written records the content after the write, so that undo can detect later changes,
+// and discard removes the entry again when the write fails or is rolled back.
+func snapshot(cfg config.Config, path, operation string) (written, discard func() error, err error) {
+	j, err := newJournal(cfg)
 	if err != nil {
-		return fmt.Errorf("cost calculation error: %v", err)
+		return nil, nil, err
 	}
-	c.logger.Info("cost information", "costInfo", costInfo)
-	// completion := resp.Choices[0].Message.Content
-
-	return completionFunc(completion)
+	if j == nil {
+		noop := func() error { return nil }
+		return noop, noop, nil
+	}
+	entry, err := j.Record(path, operation)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fail in recording snapshot: %v", err)
+	}
+	written = func() error {
+		if err := j.Complete(entry); err != nil {
+			return fmt.Errorf("fail in recording snapshot: %v", err)
+		}
+		return nil
+	}
+	discard = func() error {
+		if err := j.Discard(entry); err != nil {
+			return fmt.Errorf("fail in discarding snapshot: %v", err)
+		}
+		return nil
+	}
+	return written, discard, nil
 }
 
-func (c *OpenAIController) ControlStreaming(sysMsg, usrMsg string, quality config.QualityConfig, completionFunc func(res openai.ChatCompletionChunk) error) error {
-	// Use default values if configuration values are 0
-	maxTokens := quality.MaxTokens
-	temperature := quality.Temperature
-	if maxTokens == 0 {
-		maxTokens = models.DefaultMaxTokens
+// lookupOperation returns the operation of the section together with the configuration to run it with,
+// in which the model, quality and streaming settings of the operation take precedence over the default ones
+func lookupOperation(cfg config.Config, operations map[string]config.OperationConfig, kind, operation string) (config.Config, config.OperationConfig, error) {
+	opConfig, exists := operations[operation]
+	if !exists {
+		return cfg, config.OperationConfig{}, fmt.Errorf("unsupported %s operation: %s", kind, operation)
 	}
-	if temperature == 0.0 {
-		temperature = models.DefaultTemperature
+	cfg.Default = cfg.Default.ForOperation(opConfig)
+	return cfg, opConfig, nil
+}
+
+// logConfiguration logs the effective settings of the operation, after its overrides are merged
+func logConfiguration(logger *slog.Logger, operation string, def config.DefaultConfig, args ...any) {
+	logger.Info("using configuration", append([]any{
+		"operation", operation,
+		"model", def.Model,
+		"maxTokens", def.Quality.MaxTokens,
+		"temperature", def.Quality.Temperature,
+		"maxContinuations", def.Quality.MaxContinuations,
+	}, args...)...)
+}
+
+type AIController struct {
+	provider provider.Provider
+	modelID  string
+	logger   *slog.Logger
+
+	ledger    *usage.Ledger
+	operation string
+	file      string
+	budget    config.BudgetConfig
+	retry     config.RetryConfig
+
+	// lastCost is the cost of the last call, including its continuations; empty when unknown
+	lastCost string
+	mu       sync.Mutex
+
+	// reserved is the estimated cost of the calls in flight, guarded by budgetMu
+	reserved float64
+	budgetMu sync.Mutex
+}
+
+func NewAIController(p provider.Provider, modelID string, logger *slog.Logger) *AIController {
+	return &AIController{
+		provider: p,
+		modelID:  modelID,
+		logger:   logger,
 	}
+}
 
-	stream := c.client.Chat.Completions.NewStreaming(context.Background(), openai.ChatCompletionNewParams{
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage(sysMsg),
-			openai.UserMessage(usrMsg),
-		},
-		MaxTokens:   openai.Int(int64(maxTokens)),
-		Temperature: openai.Float(temperature),
-		Seed:        openai.Int(0),
-		Model:       c.modelID,
-	})
+// WithLedger records every call of the operation on the file to the usage ledger
+func (c *AIController) WithLedger(ledger *usage.Ledger, operation, file string) *AIController {
+	c.ledger = ledger
+	c.operation = operation
+	c.file = file
+	if abs, err := filepath.Abs(file); err == nil {
+		c.file = abs
+	}
+	return c
+}
 
-	// optionally, an accumulator helper can be used
-	acc := openai.ChatCompletionAccumulator{}
+// WithBudget enables the spend caps checked before every call
+func (c *AIController) WithBudget(budget config.BudgetConfig) *AIController {
+	c.budget = budget
+	return c
+}
 
-	for stream.Next() {
-		chunk := stream.Current()
-		c.logger.Debug("received chunk", "chunk", chunk)
-		acc.AddChunk(chunk)
+func (c *AIController) Control(ctx context.Context, sysMsg, usrMsg string, quality config.QualityConfig, completionFunc func(res *provider.Response) error) error {
+	return c.ControlMessages(ctx, sysMsg, userMessages(usrMsg), quality, completionFunc)
+}
 
-		if content, ok := acc.JustFinishedContent(); ok {
-			c.logger.Debug("Content stream finished:", "content", content)
+// ControlMessages is Control with a whole conversation, ending with the user's turn
+func (c *AIController) ControlMessages(ctx context.Context, sysMsg string, messages []provider.Message, quality config.QualityConfig, completionFunc func(res *provider.Response) error) error {
+	req := c.newRequest(sysMsg, messages, quality)
+	var stitched *provider.Response
+	var cost callCost
+	for round := 0; ; round++ {
+		release, err := c.checkBudget(req)
+		if err != nil {
+			return err
 		}
+		defer release()
 
-		// if using tool calls
-		if tool, ok := acc.JustFinishedToolCall(); ok {
-			c.logger.Debug("Tool call stream finished:", "index", tool.Index, "name", tool.Name, "arguments", tool.Arguments)
+		res, err := c.callWithRetry(ctx, func() (*provider.Response, error) {
+			return c.provider.Complete(ctx, req)
+		}, func() bool { return true })
+		if err != nil {
+			return err
 		}
 
-		if refusal, ok := acc.JustFinishedRefusal(); ok {
-			c.logger.Debug("Refusal stream finished:", "refusal", refusal)
+		err = cost.add(c.reportUsage(res.Usage))
+		release()
+		if err != nil {
+			return err
 		}
 
-		// it's best to use chunks after handling JustFinished events
-		if len(chunk.Choices) > 0 {
-			if err := completionFunc(chunk); err != nil {
-				return fmt.Errorf("fail in calling completionFunc: %v", err)
+		if stitched == nil {
+			stitched = res
+		} else {
+			stitched = &provider.Response{
+				Content:      stitched.Content + trimOverlap(stitched.Content, res.Content),
+				FinishReason: res.FinishReason,
+				Usage:        addUsage(stitched.Usage, res.Usage),
 			}
 		}
+		if !c.shouldContinue(stitched, round, quality) {
+			break
+		}
+		req = continuationRequest(c.newRequest(sysMsg, messages, quality), stitched.Content)
 	}
+	c.setLastCost(cost)
 
-	if stream.Err() != nil {
-		return stream.Err()
-	}
+	return completionFunc(stitched)
+}
 
-	// TODO: Add cost calculation for streaming mode
-	// costInfo, err := models.CalculateCostString(c.modelID, acc.Usage)
-	// if err != nil {
-	// 	return fmt.Errorf("cost calculation error: %v", err)
-	// }
-	// c.logger.Info("cost information", "costInfo", costInfo)
-	c.logger.Info("cost information", "costInfo", "cost calculation is not supported for streaming mode")
+func (c *AIController) ControlStreaming(ctx context.Context, sysMsg, usrMsg string, quality config.QualityConfig, deltaFunc func(delta string) error) error {
+	return c.ControlStreamingMessages(ctx, sysMsg, userMessages(usrMsg), quality, deltaFunc)
+}
+
+// ControlStreamingMessages is ControlStreaming with a whole conversation, ending with the user's turn
+func (c *AIController) ControlStreamingMessages(ctx context.Context, sysMsg string, messages []provider.Message, quality config.QualityConfig, deltaFunc func(delta string) error) error {
+	req := c.newRequest(sysMsg, messages, quality)
+	var content strings.Builder
+	var cost callCost
+	for round := 0; ; round++ {
+		release, err := c.checkBudget(req)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		// The beginning of a continuation is held back until the text it repeats can be trimmed.
+		// A failed attempt is retried only while none of its output has been passed on.
+		var out *overlapTrimmer
+		emitted := false
+		res, err := c.callWithRetry(ctx, func() (*provider.Response, error) {
+			out = &overlapTrimmer{prev: content.String(), done: round == 0, out: func(delta string) error {
+				emitted = true
+				content.WriteString(delta)
+				if err := deltaFunc(delta); err != nil {
+					return fmt.Errorf("fail in calling deltaFunc: %w", err)
+				}
+				return nil
+			}}
+			return c.provider.Stream(ctx, req, func(delta string) error {
+				c.logger.Debug("received chunk", "delta", delta)
+				return out.write(delta)
+			})
+		}, func() bool { return !emitted })
+		if err != nil {
+			return err
+		}
+		if err := out.flush(); err != nil {
+			return err
+		}
+		c.logger.Debug("Content stream finished:", "content", res.Content)
+
+		err = cost.add(c.reportUsage(res.Usage))
+		release()
+		if err != nil {
+			return err
+		}
 
+		if !c.shouldContinue(&provider.Response{Content: content.String(), FinishReason: res.FinishReason}, round, quality) {
+			break
+		}
+		req = continuationRequest(c.newRequest(sysMsg, messages, quality), content.String())
+	}
+	c.setLastCost(cost)
 	return nil
 }
+
+func userMessages(usrMsg string) []provider.Message {
+	return []provider.Message{{Role: provider.RoleUser, Content: usrMsg}}
+}
+
+func (c *AIController) newRequest(sysMsg string, messages []provider.Message, quality config.QualityConfig) provider.Request {
+	// Use the default max_tokens if it is 0; a temperature of 0 is a valid setting and is sent as is
+	maxTokens := quality.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = models.DefaultMaxTokens
+	}
+
+	return provider.Request{
+		Model:       c.modelID,
+		System:      sysMsg,
+		Messages:    messages,
+		MaxTokens:   maxTokens,
+		Temperature: quality.Temperature,
+	}
+}
+
+// Model returns the model the controller sends requests to
+func (c *AIController) Model() string {
+	return c.modelID
+}
+
+// LastCost returns the cost of the last call formatted as in the logs, or an empty string when it is unknown
+func (c *AIController) LastCost() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastCost
+}
+
+func (c *AIController) setLastCost(cost callCost) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastCost = cost.String()
+}
+
+// reportUsage logs the cost of a call and records it to the usage ledger.
+// It returns the cost of the call, or nil when it is unknown.
+func (c *AIController) reportUsage(u *provider.Usage) (*float64, error) {
+	rec := usage.Record{
+		Timestamp: time.Now(),
+		Operation: c.operation,
+		File:      c.file,
+		Model:     c.modelID,
+	}
+	var cost *float64
+
+	if u == nil {
+		c.logger.Info("cost information", "costInfo", fmt.Sprintf("[%s] unknown cost (usage not reported by %s)", c.modelID, c.provider.Name()))
+	} else {
+		costInfo, err := models.CalculateCostString(c.modelID, u.PromptTokens, u.CompletionTokens)
+		if err != nil {
+			return nil, fmt.Errorf("cost calculation error: %v", err)
+		}
+		c.logger.Info("cost information", "costInfo", costInfo)
+
+		rec.PromptTokens = u.PromptTokens
+		rec.CompletionTokens = u.CompletionTokens
+		if model, err := models.GetModelByID(c.modelID); err == nil {
+			rec.Cost = model.CalculateTotalCost(u.PromptTokens, u.CompletionTokens)
+			rec.Currency = model.Currency
+			cost = &rec.Cost
+		}
+	}
+
+	if c.ledger == nil {
+		return cost, nil
+	}
+	if err := c.ledger.Append(rec); err != nil {
+		// The answer has already been generated, so a ledger failure must not discard it
+		c.logger.Warn("fail in recording usage", "ledger", c.ledger.Path(), "error", err)
+	}
+	return cost, nil
+}
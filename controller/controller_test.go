@@ -0,0 +1,265 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/journal"
+	"github.com/koooyooo/mdai/models"
+	"github.com/koooyooo/mdai/provider"
+)
+
+// fakeResponse is a scripted reply of the fake provider; err is returned after the content is streamed
+type fakeResponse struct {
+	content string
+	finish  provider.FinishReason
+	err     error
+}
+
+// fakeProvider replies with the scripted responses in order and records the requests
+type fakeProvider struct {
+	mu        sync.Mutex
+	responses []fakeResponse
+	requests  []provider.Request
+}
+
+func (p *fakeProvider) Name() models.Provider {
+	return models.ProviderOpenAI
+}
+
+func (p *fakeProvider) next(req provider.Request) fakeResponse {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
+	if len(p.responses) == 0 {
+		return fakeResponse{content: "done", finish: provider.FinishReasonStop}
+	}
+	res := p.responses[0]
+	p.responses = p.responses[1:]
+	return res
+}
+
+func (p *fakeProvider) Complete(ctx context.Context, req provider.Request) (*provider.Response, error) {
+	res := p.next(req)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &provider.Response{Content: res.content, FinishReason: res.finish, Usage: &provider.Usage{PromptTokens: 10, CompletionTokens: 5}}, nil
+}
+
+func (p *fakeProvider) Stream(ctx context.Context, req provider.Request, deltaFunc func(delta string) error) (*provider.Response, error) {
+	res := p.next(req)
+	for rest := res.content; rest != ""; {
+		n := min(len(rest), 7)
+		if err := deltaFunc(rest[:n]); err != nil {
+			return nil, err
+		}
+		rest = rest[n:]
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &provider.Response{Content: res.content, FinishReason: res.finish, Usage: &provider.Usage{PromptTokens: 10, CompletionTokens: 5}}, nil
+}
+
+func (p *fakeProvider) calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.requests)
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestResolveProvider(t *testing.T) {
+	tests := []struct {
+		name string
+		def  config.DefaultConfig
+		want models.Provider
+	}{
+		{"catalog model", config.DefaultConfig{Model: "claude-3-haiku-20240307"}, models.ProviderAnthropic},
+		{"explicit provider", config.DefaultConfig{Model: "claude-3-haiku-20240307", Provider: "google"}, models.ProviderGoogle},
+		{"unknown model", config.DefaultConfig{Model: "llama3"}, models.ProviderOpenAI},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveProvider(tt.def)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveProvider() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := resolveProvider(config.DefaultConfig{Provider: "unknown"}); err == nil {
+		t.Error("resolveProvider() with an unknown provider succeeded")
+	}
+}
+
+// useFakeProvider makes the operations send their requests to p
+func useFakeProvider(t *testing.T, p provider.Provider) {
+	orig := newProvider
+	newProvider = func(config.DefaultConfig) (provider.Provider, error) {
+		return p, nil
+	}
+	t.Cleanup(func() { newProvider = orig })
+}
+
+func testConfig() config.Config {
+	cfg := *config.GetDefaultConfig()
+	cfg.Usage.Disable = true
+	cfg.Journal.Disable = true
+	return cfg
+}
+
+func TestTransformWithFakeProvider(t *testing.T) {
+	p := &fakeProvider{responses: []fakeResponse{{content: "# Summary\n", finish: provider.FinishReasonStop}}}
+	useFakeProvider(t, p)
+
+	path := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(path, []byte("# Notes\n\nSome content.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Transform(context.Background(), testConfig(), "summarize", path, nil, testLogger()); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(filepath.Dir(path), "notes_sum.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "# Summary\n" {
+		t.Errorf("output = %q", got)
+	}
+	if len(p.requests) != 1 || !strings.Contains(p.requests[0].Messages[0].Content, "Some content.") {
+		t.Errorf("requests = %+v", p.requests)
+	}
+}
+
+func TestAppendWithFakeProvider(t *testing.T) {
+	p := &fakeProvider{responses: []fakeResponse{{content: "It is 42.", finish: provider.FinishReasonStop}}}
+	useFakeProvider(t, p)
+
+	path := filepath.Join(t.TempDir(), "qa.md")
+	if err := os.WriteFile(path, []byte("# Notes\n\n> What is the answer?\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(context.Background(), testConfig(), "answer", path, nil, testLogger()); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(got)
+	if !strings.HasPrefix(content, "# Notes\n\n> What is the answer?\n") {
+		t.Errorf("question not kept: %q", content)
+	}
+	open := strings.Index(content, "<!-- mdai:answer")
+	answer := strings.Index(content, "It is 42.")
+	end := strings.Index(content, "<!-- /mdai:answer")
+	if open < 0 || answer < open || end < answer {
+		t.Errorf("answer not written between markers: %q", content)
+	}
+	if len(p.requests) != 1 || !strings.Contains(p.requests[0].Messages[0].Content, "What is the answer?") {
+		t.Errorf("requests = %+v", p.requests)
+	}
+}
+
+// cancelProvider streams a partial answer and then cancels the run
+type cancelProvider struct {
+	fakeProvider
+	cancel context.CancelFunc
+}
+
+func (p *cancelProvider) Stream(ctx context.Context, req provider.Request, deltaFunc func(delta string) error) (*provider.Response, error) {
+	if err := deltaFunc("A partial"); err != nil {
+		return nil, err
+	}
+	p.cancel()
+	return nil, ctx.Err()
+}
+
+func TestAppendRollbackDiscardsSnapshot(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	useFakeProvider(t, &cancelProvider{cancel: cancel})
+
+	dir := t.TempDir()
+	cfg := testConfig()
+	cfg.Journal = config.JournalConfig{Dir: filepath.Join(dir, "journal")}
+	path := filepath.Join(dir, "qa.md")
+	original := "# Notes\n\n> What is the answer?\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Append(ctx, cfg, "answer", path, nil, testLogger()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Append() = %v, want context.Canceled", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != original {
+		t.Errorf("content after rollback = %q", got)
+	}
+	entries, err := journal.New(cfg.Journal.Dir).Entries(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("journal keeps %d entries of the rolled back answer", len(entries))
+	}
+}
+
+func TestAppendFailureRemovesPartialAnswer(t *testing.T) {
+	p := &fakeProvider{responses: []fakeResponse{
+		{content: "A partial ```go\nanswer", err: errors.New("connection reset")},
+		{content: "It is 42.", finish: provider.FinishReasonStop},
+	}}
+	useFakeProvider(t, p)
+
+	dir := t.TempDir()
+	cfg := testConfig()
+	cfg.Journal = config.JournalConfig{Dir: filepath.Join(dir, "journal")}
+	path := filepath.Join(dir, "qa.md")
+	original := "# Notes\n\n> What is the answer?\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Append(context.Background(), cfg, "answer", path, nil, testLogger()); err == nil {
+		t.Fatal("Append() succeeded, want the stream error")
+	}
+	if got, _ := os.ReadFile(path); string(got) != original {
+		t.Errorf("content after the failure = %q", got)
+	}
+	entries, err := journal.New(cfg.Journal.Dir).Entries(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("journal keeps %d entries of the failed answer", len(entries))
+	}
+
+	// The question is still found and answered by the next run
+	if err := Append(context.Background(), cfg, "answer", path, nil, testLogger()); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	if !strings.Contains(string(got), "It is 42.") || strings.Contains(string(got), "partial") {
+		t.Errorf("content after the rerun = %q", got)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/util/diff"
+	"github.com/koooyooo/mdai/util/file"
+)
+
+// Edit rewrites a markdown file in place with the result of an edit operation.
+// The changes are written to out as a unified diff and applied only when confirmed, or when yes is set.
+// The original content is kept in a backup file next to the file and in the undo journal.
+func Edit(ctx context.Context, cfg config.Config, operation string, path string, extraArgs []string, yes bool, out io.Writer, logger *slog.Logger) error {
+	// Get operation configuration dynamically
+	cfg, opConfig, err := getEditOperationConfig(cfg, operation)
+	if err != nil {
+		return err
+	}
+
+	// Validate arguments using configuration
+	if err := validateArgs(extraArgs, opConfig.Args); err != nil {
+		return err
+	}
+
+	// Edit operations are generated like transformations, without an output file
+	transformConfig := &TransformConfig{
+		Operation:     operation,
+		SystemMessage: opConfig.SystemMessage,
+		UserMessage:   opConfig.UserMessage,
+		Chunking:      opConfig.Chunking,
+		ExtraArgs:     extraArgs,
+	}
+
+	if err := validateFile(path); err != nil {
+		return err
+	}
+	content, err := file.LoadContent(path)
+	if err != nil {
+		return fmt.Errorf("fail in loading content: %v", err)
+	}
+
+	result, err := generateTransform(ctx, cfg, transformConfig, path, content, extraArgs, logger)
+	if err != nil {
+		return fmt.Errorf("fail in executing edit: %w", err)
+	}
+	// Models tend to drop the final newline, which would show up as a change of the last line
+	if strings.HasSuffix(content, "\n") && !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+
+	patch := diff.Unified("a/"+path, "b/"+path, content, result)
+	if patch == "" {
+		logger.Info("no changes", "path", path)
+		return nil
+	}
+	if _, err := io.WriteString(out, patch); err != nil {
+		return fmt.Errorf("fail in writing diff: %v", err)
+	}
+
+	if !yes {
+		ok, err := confirm(fmt.Sprintf("Apply the changes to %s?", path))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			logger.Info("changes discarded", "path", path)
+			return nil
+		}
+	}
+
+	backupPath := path + cfg.Edit.GetBackupSuffix()
+	if err := applyEdit(cfg, operation, path, backupPath, content, result); err != nil {
+		return err
+	}
+
+	logger.Info("edit applied successfully",
+		"path", path,
+		"backup", backupPath)
+
+	return nil
+}
+
+func getEditOperationConfig(cfg config.Config, operation string) (config.Config, config.OperationConfig, error) {
+	return lookupOperation(cfg, cfg.Edit.Operations, "edit", operation)
+}
+
+// applyEdit replaces the content of the file atomically after backing up the original.
+// The file must not have changed while the edit was generated.
+func applyEdit(cfg config.Config, operation, path, backupPath, original, result string) error {
+	if err := checkUnchanged(path, original); err != nil {
+		return err
+	}
+
+	if err := file.WriteFileAtomic(backupPath, []byte(original)); err != nil {
+		return fmt.Errorf("fail in writing backup: %v", err)
+	}
+	written, discard, err := snapshot(cfg, path, operation)
+	if err != nil {
+		return err
+	}
+	if err := file.WriteFileAtomic(path, []byte(result)); err != nil {
+		_ = discard()
+		return fmt.Errorf("fail in saving result: %v", err)
+	}
+	if err := written(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkUnchanged makes sure the file was not modified while its new content was generated
+func checkUnchanged(path, original string) error {
+	current, err := file.LoadContent(path)
+	if err != nil {
+		return fmt.Errorf("fail in loading content: %v", err)
+	}
+	if current != original {
+		return fmt.Errorf("file changed while the result was generated: %s", path)
+	}
+	return nil
+}
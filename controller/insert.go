@@ -0,0 +1,175 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/markdown"
+	"github.com/koooyooo/mdai/util/file"
+)
+
+// Insert places the result of an insert operation at the anchor of a markdown file, between insert markers.
+// A block generated earlier by the operation is replaced in place, so that running it again updates the block.
+func Insert(ctx context.Context, cfg config.Config, operation string, path string, extraArgs []string, logger *slog.Logger) error {
+	// Get operation configuration dynamically
+	cfg, opConfig, err := getInsertOperationConfig(cfg, operation)
+	if err != nil {
+		return err
+	}
+
+	// Validate arguments using configuration
+	if err := validateArgs(extraArgs, opConfig.Args); err != nil {
+		return err
+	}
+
+	// Insert operations are generated like transformations, without an output file
+	transformConfig := &TransformConfig{
+		Operation:     operation,
+		SystemMessage: opConfig.SystemMessage,
+		UserMessage:   opConfig.UserMessage,
+		Chunking:      opConfig.Chunking,
+		ExtraArgs:     extraArgs,
+	}
+
+	if err := validateFile(path); err != nil {
+		return err
+	}
+	content, err := file.LoadContent(path)
+	if err != nil {
+		return fmt.Errorf("fail in loading content: %v", err)
+	}
+
+	// The anchor is resolved before the call, so that a missing heading or marker costs nothing
+	existing := file.FindInsertion(content, operation)
+	start, end := 0, 0
+	if existing != nil {
+		// Without its closing marker the end of the block is unknown, and splicing could remove the text after it
+		if !existing.Closed {
+			return fmt.Errorf("the %s block has no closing %s marker; add it after the generated text", operation, file.InsertCloseMarker())
+		}
+		start, end = existing.Offset, existing.End
+	} else if start, end, err = anchorRange(content, opConfig.Anchor); err != nil {
+		return err
+	}
+
+	result, err := generateTransform(ctx, cfg, transformConfig, path, insertContext(content), extraArgs, logger)
+	if err != nil {
+		return fmt.Errorf("fail in executing insertion: %w", err)
+	}
+
+	block := file.InsertOpenMarker(operation, cfg.Default.Model, time.Now()) + "\n" +
+		blockBody(result) + "\n\n" +
+		file.InsertCloseMarker() + "\n"
+	if err := checkUnchanged(path, content); err != nil {
+		return err
+	}
+	written, discard, err := snapshot(cfg, path, operation)
+	if err != nil {
+		return err
+	}
+	if err := file.WriteFileAtomic(path, []byte(spliceBlock(content, start, end, block))); err != nil {
+		_ = discard()
+		return fmt.Errorf("fail in saving result: %v", err)
+	}
+	if err := written(); err != nil {
+		return err
+	}
+
+	logger.Info("insertion completed successfully",
+		"path", path,
+		"operation", operation,
+		"updated", existing != nil)
+
+	return nil
+}
+
+func getInsertOperationConfig(cfg config.Config, operation string) (config.Config, config.OperationConfig, error) {
+	return lookupOperation(cfg, cfg.Insert.Operations, "insert", operation)
+}
+
+// insertContext returns the content without the generated blocks, so that they are not summarized again
+func insertContext(content string) string {
+	var b strings.Builder
+	last := 0
+	for _, ins := range file.Insertions(content) {
+		b.WriteString(content[last:ins.Offset])
+		last = ins.End
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// blockBody returns the generated text without surrounding blank lines, with a code block it leaves open closed,
+// so that the closing marker written after a blank line is not read as part of the text
+func blockBody(result string) string {
+	body := strings.Trim(result, "\n")
+	var fence markdown.Fence
+	for _, line := range strings.Split(body, "\n") {
+		fence.Next(strings.TrimRight(line, "\r"))
+	}
+	if closing := fence.Closing(); closing != "" {
+		body += "\n" + closing
+	}
+	return body
+}
+
+// anchorRange returns the range of the content replaced by a new block; it is empty unless a marker is replaced.
+// The front matter always stays first, so top is after it as well.
+func anchorRange(content string, anchor config.AnchorConfig) (int, int, error) {
+	doc := markdown.Parse(content)
+	switch anchor.Position {
+	case "", config.AnchorTop, config.AnchorAfterFrontMatter:
+		if fm := doc.FrontMatter(); fm != nil {
+			return fm.End, fm.End, nil
+		}
+		return 0, 0, nil
+	case config.AnchorBeforeHeading, config.AnchorAfterHeading:
+		if anchor.Heading == "" {
+			return 0, 0, fmt.Errorf("anchor heading is required for %s", anchor.Position)
+		}
+		re, err := regexp.Compile(anchor.Heading)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid anchor heading: %v", err)
+		}
+		for _, h := range doc.Headings() {
+			if !re.MatchString(h.Text) {
+				continue
+			}
+			if anchor.Position == config.AnchorBeforeHeading {
+				return h.Offset, h.Offset, nil
+			}
+			return h.End, h.End, nil
+		}
+		return 0, 0, fmt.Errorf("no heading matches %q", anchor.Heading)
+	case config.AnchorMarker:
+		marker := file.FindMarker(content, anchor.Marker)
+		if marker == nil {
+			return 0, 0, fmt.Errorf("marker %q not found", anchor.Marker)
+		}
+		return marker.Offset, marker.End, nil
+	}
+	return 0, 0, fmt.Errorf("unsupported anchor position: %s", anchor.Position)
+}
+
+// spliceBlock replaces the range of the content with the block, keeping a blank line before and after it
+func spliceBlock(content string, start, end int, block string) string {
+	head, tail := content[:start], content[end:]
+	if head != "" && !strings.HasSuffix(head, "\n") {
+		head += "\n"
+	}
+	if head != "" && !strings.HasSuffix(head, "\n\n") {
+		head += "\n"
+	}
+	if tail != "" && !strings.HasPrefix(tail, "\n") {
+		tail = "\n" + tail
+	}
+	return head + block + tail
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/koooyooo/mdai/provider"
+	"github.com/koooyooo/mdai/util/file"
+)
+
+func TestBlockBody(t *testing.T) {
+	tests := []struct {
+		result string
+		want   string
+	}{
+		{"\n\nsummary\n\n", "summary"},
+		{"<div>x</div>\n", "<div>x</div>"},
+		{"text\n```go\ncode\n", "text\n```go\ncode\n```"},
+		{"~~~~\ncode\n~~~\n", "~~~~\ncode\n~~~\n~~~~"},
+		{"```\ncode\n```\n", "```\ncode\n```"},
+	}
+	for _, tt := range tests {
+		if got := blockBody(tt.result); got != tt.want {
+			t.Errorf("blockBody(%q) = %q, want %q", tt.result, got, tt.want)
+		}
+	}
+}
+
+func TestInsertRerunReplacesBlock(t *testing.T) {
+	// Results ending in raw HTML or an open fence must not hide the closing marker from the rerun
+	p := &fakeProvider{responses: []fakeResponse{
+		{content: "<div>x</div>", finish: provider.FinishReasonStop},
+		{content: "```go\ncode", finish: provider.FinishReasonStop},
+		{content: "**TL;DR** done", finish: provider.FinishReasonStop},
+	}}
+	useFakeProvider(t, p)
+
+	path := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(path, []byte("# Notes\n\nSome content.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := Insert(context.Background(), testConfig(), "tldr", path, nil, testLogger()); err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(got)
+	if n := strings.Count(content, file.InsertCloseMarker()); n != 1 {
+		t.Errorf("file has %d blocks, want 1:\n%s", n, content)
+	}
+	if !strings.Contains(content, "**TL;DR** done\n\n"+file.InsertCloseMarker()+"\n\n# Notes\n\nSome content.\n") {
+		t.Errorf("content =\n%s", content)
+	}
+}
+
+func TestInsertRefusesUnclosedBlock(t *testing.T) {
+	p := &fakeProvider{}
+	useFakeProvider(t, p)
+
+	path := filepath.Join(t.TempDir(), "notes.md")
+	original := `<!-- mdai:insert op="tldr" model="m" time="t" -->` + "\nold summary\n\n# Notes\n\nSome content.\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Insert(context.Background(), testConfig(), "tldr", path, nil, testLogger()); err == nil {
+		t.Fatal("Insert() succeeded, want an error for the missing closing marker")
+	}
+	if got, _ := os.ReadFile(path); string(got) != original {
+		t.Errorf("content changed to %q", got)
+	}
+	if p.calls() != 0 {
+		t.Errorf("provider called %d times", p.calls())
+	}
+}
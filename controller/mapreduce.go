@@ -0,0 +1,126 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/models"
+	"github.com/koooyooo/mdai/provider"
+	"github.com/koooyooo/mdai/tokenizer"
+)
+
+// maxReduceDepth limits how often partial results are reduced again
+const maxReduceDepth = 3
+
+// promptLimit returns the maximum prompt tokens per request, or 0 when it is unknown
+func promptLimit(modelID string, chunking config.ChunkingConfig, quality config.QualityConfig) int {
+	if chunking.MaxTokens > 0 {
+		return chunking.MaxTokens
+	}
+	model, err := models.GetModelByID(modelID)
+	if err != nil {
+		return 0
+	}
+	maxTokens := quality.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = models.DefaultMaxTokens
+	}
+	// The completion shares the context window with the prompt
+	return model.ContextSize - maxTokens
+}
+
+// countPromptTokens counts the tokens of a system and user message pair
+func countPromptTokens(modelID, sysMsg, userMsg string) int {
+	sysTokens, _ := tokenizer.Count(modelID, sysMsg)
+	userTokens, _ := tokenizer.Count(modelID, userMsg)
+	return sysTokens + userTokens + 2*tokenizer.TokensPerMessage + tokenizer.TokensPerReply
+}
+
+// complete runs a non-streaming request and returns the generated content
+func complete(ctx context.Context, c *AIController, sysMsg, userMsg string, quality config.QualityConfig) (string, error) {
+	var content string
+	err := c.Control(ctx, sysMsg, userMsg, quality, func(res *provider.Response) error {
+		content = res.Content
+		return nil
+	})
+	return content, err
+}
+
+// mapReduce splits the content at heading boundaries so that each request fits in limit,
+// runs the operation on each chunk, then combines the partial results in a reduce pass
+func mapReduce(ctx context.Context, c *AIController, cfg config.Config, transformConfig *TransformConfig, content string, templateVars map[string]string, limit int, depth int, logger *slog.Logger) (string, error) {
+	sysMsg := transformConfig.SystemMessage
+	vars := copyVars(templateVars)
+
+	// Tokens taken by the system message and the template itself
+	vars["Content"] = ""
+	emptyMsg, err := transformConfig.UserMessage.Apply(vars)
+	if err != nil {
+		return "", fmt.Errorf("fail in creating user message: %v", err)
+	}
+	chunkBudget := limit - countPromptTokens(c.modelID, sysMsg, emptyMsg)
+	if chunkBudget <= 0 {
+		return "", fmt.Errorf("system and user messages alone exceed the prompt limit of %d tokens", limit)
+	}
+
+	count := func(s string) int {
+		n, _ := tokenizer.Count(c.modelID, s)
+		return n
+	}
+	chunks := splitChunks(content, chunkBudget, count)
+	logger.Info("content exceeds the prompt limit, processing in chunks",
+		"limit", limit,
+		"chunks", len(chunks),
+		"depth", depth)
+
+	// Map
+	partials := make([]string, 0, len(chunks))
+	for i, chunk := range chunks {
+		vars["Content"] = chunk
+		userMsg, err := transformConfig.UserMessage.Apply(vars)
+		if err != nil {
+			return "", fmt.Errorf("fail in creating user message: %v", err)
+		}
+		logger.Info("processing chunk", "chunk", i+1, "of", len(chunks))
+		partial, err := complete(ctx, c, sysMsg, userMsg, cfg.Default.Quality)
+		if err != nil {
+			return "", fmt.Errorf("fail in processing chunk %d: %w", i+1, err)
+		}
+		partials = append(partials, strings.TrimSpace(partial))
+	}
+
+	// Reduce
+	reduceTemplate := transformConfig.Chunking.ReduceMessage
+	if reduceTemplate.Template == "" {
+		reduceTemplate.Template = config.DefaultReduceMessage
+	}
+	vars["Content"] = strings.Join(partials, "\n\n---\n\n")
+	reduceMsg, err := reduceTemplate.Apply(vars)
+	if err != nil {
+		return "", fmt.Errorf("fail in creating reduce message: %v", err)
+	}
+
+	if countPromptTokens(c.modelID, sysMsg, reduceMsg) > limit {
+		if depth >= maxReduceDepth {
+			return "", fmt.Errorf("partial results still exceed the prompt limit after %d reduce passes", depth)
+		}
+		return mapReduce(ctx, c, cfg, transformConfig, vars["Content"], templateVars, limit, depth+1, logger)
+	}
+
+	logger.Info("combining partial results", "partials", len(partials))
+	return complete(ctx, c, sysMsg, reduceMsg, cfg.Default.Quality)
+}
+
+func copyVars(vars map[string]string) map[string]string {
+	copied := make(map[string]string, len(vars))
+	for k, v := range vars {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"fmt"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/provider"
+	"github.com/koooyooo/mdai/util/file"
+)
+
+// RenderPrompt renders the system message and the conversation that an append, transform, edit or insert
+// operation would send for the file, without calling the API
+func RenderPrompt(cfg config.Config, operation string, path string, extraArgs []string) (string, []provider.Message, error) {
+	content, err := file.LoadContent(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("fail in loading content: %v", err)
+	}
+
+	if opConfig, exists := cfg.Append.Operations[operation]; exists {
+		return prepareAppendMessages(cfg, &AppendConfig{
+			Operation:     operation,
+			SystemMessage: opConfig.SystemMessage,
+			UserMessage:   opConfig.UserMessage,
+			ExtraArgs:     extraArgs,
+		}, content, extraArgs)
+	}
+
+	opConfig, exists := cfg.Transform.Operations[operation]
+	if !exists {
+		opConfig, exists = cfg.Edit.Operations[operation]
+	}
+	if !exists {
+		if opConfig, exists = cfg.Insert.Operations[operation]; exists {
+			content = insertContext(content)
+		}
+	}
+	if exists {
+		if err := validateArgs(extraArgs, opConfig.Args); err != nil {
+			return "", nil, err
+		}
+		sysMsg, userMsg, err := prepareMessages(cfg, &TransformConfig{
+			Operation:      operation,
+			SystemMessage:  opConfig.SystemMessage,
+			UserMessage:    opConfig.UserMessage,
+			SuffixTemplate: opConfig.Suffix,
+			ExtraArgs:      extraArgs,
+		}, content, extraArgs)
+		if err != nil {
+			return "", nil, err
+		}
+		return sysMsg, userMessages(userMsg), nil
+	}
+
+	return "", nil, fmt.Errorf("unsupported operation: %s", operation)
+}
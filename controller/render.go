@@ -0,0 +1,215 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/markdown"
+	"github.com/koooyooo/mdai/util/file"
+)
+
+// Render fills or refreshes the directive regions of a markdown file, or of every markdown file under a directory.
+// A region whose input hash is unchanged is skipped unless force is set.
+func Render(ctx context.Context, cfg config.Config, path string, force bool, logger *slog.Logger) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("file not found: %s", path)
+	}
+	if !info.IsDir() {
+		if err := validateFile(path); err != nil {
+			return err
+		}
+		return renderFile(ctx, cfg, path, force, logger)
+	}
+
+	var paths []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p != path && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(strings.ToLower(p), ".md") {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("fail in walking directory: %v", err)
+	}
+
+	for _, p := range paths {
+		if err := renderFile(ctx, cfg, p, force, logger); err != nil {
+			return fmt.Errorf("fail in rendering %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// renderFile generates the regions of the file whose input changed and writes them back at once.
+// Regions completed before a failure are still written, so that a rerun skips them.
+func renderFile(ctx context.Context, cfg config.Config, path string, force bool, logger *slog.Logger) error {
+	content, err := file.LoadContent(path)
+	if err != nil {
+		return fmt.Errorf("fail in loading content: %v", err)
+	}
+	regions := file.Regions(content)
+	if len(regions) == 0 {
+		logger.Debug("no directive region found", "path", path)
+		return nil
+	}
+
+	// Inputs are taken from the original content, so that they do not depend on the order of rendering
+	replacements := map[*file.Region]string{}
+	skipped := 0
+	var genErr error
+	for _, r := range regions {
+		regionCfg, opConfig, err := getRegionOperationConfig(cfg, r.Operation)
+		if err != nil {
+			genErr = fmt.Errorf("line %d: %v", r.Line, err)
+			break
+		}
+		input, err := regionInput(content, regions, r)
+		if err != nil {
+			genErr = fmt.Errorf("line %d: %v", r.Line, err)
+			break
+		}
+		extraArgs := strings.Fields(r.Attrs["args"])
+		hash := regionHash(r.Operation, extraArgs, regionCfg.Default, opConfig, input)
+		if !force && r.Hash() == hash && r.CloseMarker != "" {
+			logger.Debug("region unchanged, skipped", "path", path, "line", r.Line, "operation", r.Operation)
+			skipped++
+			continue
+		}
+
+		if err := validateArgs(extraArgs, opConfig.Args); err != nil {
+			genErr = fmt.Errorf("line %d: %v", r.Line, err)
+			break
+		}
+		transformConfig := &TransformConfig{
+			Operation:     r.Operation,
+			SystemMessage: opConfig.SystemMessage,
+			UserMessage:   opConfig.UserMessage,
+			Chunking:      opConfig.Chunking,
+			ExtraArgs:     extraArgs,
+		}
+		logger.Info("rendering region", "path", path, "line", r.Line, "operation", r.Operation)
+		result, err := generateTransform(ctx, regionCfg, transformConfig, path, input, extraArgs, logger)
+		if err != nil {
+			genErr = fmt.Errorf("line %d: %w", r.Line, err)
+			break
+		}
+		closeMarker := r.CloseMarker
+		if closeMarker == "" {
+			closeMarker = file.RegionCloseMarker
+		}
+		replacements[r] = r.WithHash(hash) + "\n" + blockBody(result) + "\n\n" + closeMarker + "\n"
+	}
+
+	if len(replacements) > 0 {
+		if err := writeRegions(cfg, path, content, regions, replacements); err != nil {
+			return err
+		}
+	}
+	if genErr != nil {
+		return genErr
+	}
+
+	logger.Info("render completed successfully",
+		"path", path,
+		"rendered", len(replacements),
+		"skipped", skipped)
+
+	return nil
+}
+
+// getRegionOperationConfig finds the operation of a region; append operations need a question and cannot be used
+func getRegionOperationConfig(cfg config.Config, operation string) (config.Config, config.OperationConfig, error) {
+	for _, operations := range []map[string]config.OperationConfig{cfg.Transform.Operations, cfg.Edit.Operations, cfg.Insert.Operations} {
+		if _, exists := operations[operation]; exists {
+			return lookupOperation(cfg, operations, "region", operation)
+		}
+	}
+	return cfg, config.OperationConfig{}, fmt.Errorf("unsupported region operation: %s", operation)
+}
+
+// regionInput returns the content the region is generated from: the section named by its section attribute,
+// or the whole document, without any directive region
+func regionInput(content string, regions []*file.Region, r *file.Region) (string, error) {
+	section, ok := r.Attrs["section"]
+	if !ok {
+		return file.WithoutRegions(content, regions, 0, len(content)), nil
+	}
+
+	headings := markdown.Parse(content).Headings()
+	for i, h := range headings {
+		if !strings.EqualFold(h.Text, strings.TrimSpace(section)) {
+			continue
+		}
+		end := len(content)
+		for _, next := range headings[i+1:] {
+			if next.Level <= h.Level {
+				end = next.Offset
+				break
+			}
+		}
+		return file.WithoutRegions(content, regions, h.Offset, end), nil
+	}
+	return "", fmt.Errorf("section %q not found", section)
+}
+
+// regionHash identifies the input of a region: the operation, its prompts, the arguments, the content
+// and the model and quality settings it runs with
+func regionHash(operation string, extraArgs []string, def config.DefaultConfig, opConfig config.OperationConfig, input string) string {
+	settings := fmt.Sprintf("%s %s %d %g %d", def.Provider, def.Model, def.Quality.MaxTokens, def.Quality.Temperature, def.Quality.MaxContinuations)
+	h := sha256.New()
+	for _, s := range []string{operation, strings.Join(extraArgs, " "), opConfig.SystemMessage, opConfig.UserMessage.Template, settings, input} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// writeRegions replaces the generated regions in the content and writes the file atomically
+func writeRegions(cfg config.Config, path, content string, regions []*file.Region, replacements map[*file.Region]string) error {
+	var b strings.Builder
+	pos := 0
+	for _, r := range regions {
+		replacement, ok := replacements[r]
+		if !ok {
+			continue
+		}
+		b.WriteString(content[pos:r.Offset])
+		b.WriteString(replacement)
+		pos = r.End
+	}
+	b.WriteString(content[pos:])
+
+	if err := checkUnchanged(path, content); err != nil {
+		return err
+	}
+	written, discard, err := snapshot(cfg, path, "render")
+	if err != nil {
+		return err
+	}
+	if err := file.WriteFileAtomic(path, []byte(b.String())); err != nil {
+		_ = discard()
+		return fmt.Errorf("fail in saving result: %v", err)
+	}
+	if err := written(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/koooyooo/mdai/provider"
+)
+
+func TestRenderSkipsUnchangedRegion(t *testing.T) {
+	// A result ending in raw HTML must not hide the closing marker from the rerun
+	p := &fakeProvider{responses: []fakeResponse{{content: "<div>x</div>", finish: provider.FinishReasonStop}}}
+	useFakeProvider(t, p)
+
+	path := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(path, []byte("# Notes\n\nSome content.\n\n<!-- mdai:summarize -->\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Render(context.Background(), testConfig(), path, false, testLogger()); err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(rendered), "-->\n<div>x</div>\n\n<!-- /mdai -->\n") {
+		t.Errorf("rendered content = %q", rendered)
+	}
+
+	if err := Render(context.Background(), testConfig(), path, false, testLogger()); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	if string(got) != string(rendered) {
+		t.Errorf("rerun changed the file:\n%s", got)
+	}
+	if p.calls() != 1 {
+		t.Errorf("provider called %d times, want 1", p.calls())
+	}
+}
+
+func TestRenderReplacesRegionEndingInHTML(t *testing.T) {
+	p := &fakeProvider{responses: []fakeResponse{{content: "New summary.", finish: provider.FinishReasonStop}}}
+	useFakeProvider(t, p)
+
+	// Written without a blank line before the closing marker, the raw HTML block runs into it
+	path := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(path, []byte("# Notes\n\n<!-- mdai:summarize -->\n<div>x</div>\n<!-- /mdai -->\n\nAfter.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Render(context.Background(), testConfig(), path, false, testLogger()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(got)
+	if strings.Contains(content, "<div>x</div>") || strings.Count(content, "<!-- /mdai -->") != 1 {
+		t.Errorf("region not replaced in place:\n%s", content)
+	}
+	if !strings.HasSuffix(content, "-->\nNew summary.\n\n<!-- /mdai -->\n\nAfter.\n") {
+		t.Errorf("content = %q", content)
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/provider"
+)
+
+const (
+	defaultMaxRetries     = 3
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// WithRetry sets how transient API errors are retried
+func (c *AIController) WithRetry(retry config.RetryConfig) *AIController {
+	c.retry = retry
+	return c
+}
+
+// callWithRetry calls the provider and retries rate limit, server and network errors with
+// exponential backoff, waiting at least as long as Retry-After asks.
+// canRetry reports whether the failed attempt left nothing behind, e.g. no streamed output.
+func (c *AIController) callWithRetry(ctx context.Context, call func() (*provider.Response, error), canRetry func() bool) (*provider.Response, error) {
+	maxRetries := c.retry.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+	backoff := c.retry.InitialBackoff
+	if backoff <= 0 {
+		backoff = defaultInitialBackoff
+	}
+	maxBackoff := c.retry.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := call()
+		if err == nil {
+			return res, nil
+		}
+		apiErr, ok := provider.AsError(err)
+		if !ok || !apiErr.Retryable() || attempt >= maxRetries || !canRetry() {
+			return nil, err
+		}
+
+		delay := backoffDelay(backoff, maxBackoff, attempt)
+		if apiErr.RetryAfter > delay {
+			delay = apiErr.RetryAfter
+		}
+		c.logger.Warn("API call failed, retrying", "kind", apiErr.Kind, "attempt", attempt+1, "of", maxRetries, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoffDelay returns the wait before the retry of the attempt, doubling from backoff up to maxBackoff
+func backoffDelay(backoff, maxBackoff time.Duration, attempt int) time.Duration {
+	delay := min(backoff, maxBackoff)
+	for i := 0; i < attempt && delay < maxBackoff; i++ {
+		delay = min(delay*2, maxBackoff)
+	}
+	return delay
+}
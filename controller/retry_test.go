@@ -0,0 +1,105 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/models"
+	"github.com/koooyooo/mdai/provider"
+)
+
+func apiError(kind provider.ErrorKind) error {
+	return &provider.Error{Kind: kind, Provider: models.ProviderOpenAI, Message: string(kind)}
+}
+
+func TestCallWithRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		errs     []error
+		canRetry bool
+		calls    int
+		wantErr  bool
+	}{
+		{"success", nil, true, 1, false},
+		{"server errors recover", []error{apiError(provider.ErrorKindServer), apiError(provider.ErrorKindNetwork)}, true, 3, false},
+		{"rate limit exhausts retries", []error{apiError(provider.ErrorKindRateLimit), apiError(provider.ErrorKindRateLimit), apiError(provider.ErrorKindRateLimit)}, true, 3, true},
+		{"auth is not retried", []error{apiError(provider.ErrorKindAuth)}, true, 1, true},
+		{"context length is not retried", []error{apiError(provider.ErrorKindContextLength)}, true, 1, true},
+		{"other errors are not retried", []error{errors.New("boom")}, true, 1, true},
+		{"output already written", []error{apiError(provider.ErrorKindNetwork)}, false, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			_, err := testController(&fakeProvider{}).callWithRetry(context.Background(), func() (*provider.Response, error) {
+				calls++
+				if calls <= len(tt.errs) {
+					return nil, tt.errs[calls-1]
+				}
+				return &provider.Response{Content: "ok"}, nil
+			}, func() bool { return tt.canRetry })
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if calls != tt.calls {
+				t.Errorf("calls = %d, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestCallWithRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewAIController(&fakeProvider{}, "gpt-4o-mini", testLogger()).WithRetry(config.RetryConfig{InitialBackoff: time.Hour, MaxBackoff: time.Hour})
+	_, err := c.callWithRetry(ctx, func() (*provider.Response, error) {
+		cancel()
+		return nil, apiError(provider.ErrorKindServer)
+	}, func() bool { return true })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
+
+func TestControlStreamingNotRetriedAfterOutput(t *testing.T) {
+	p := &fakeProvider{responses: []fakeResponse{
+		{content: "partial", err: apiError(provider.ErrorKindNetwork)},
+	}}
+	var out strings.Builder
+	err := testController(p).ControlStreaming(context.Background(), "sys", "question", config.QualityConfig{}, func(delta string) error {
+		out.WriteString(delta)
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected the network error")
+	}
+	if p.calls() != 1 || out.String() != "partial" {
+		t.Errorf("calls = %d, output = %q; want a single call", p.calls(), out.String())
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{4, 16 * time.Second},
+		{5, 30 * time.Second},
+		// A shift by the attempt would overflow here
+		{40, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoffDelay(time.Second, 30*time.Second, tt.attempt); got != tt.want {
+			t.Errorf("backoffDelay(attempt %d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
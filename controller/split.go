@@ -0,0 +1,186 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/markdown"
+	"github.com/koooyooo/mdai/models"
+	"github.com/koooyooo/mdai/tokenizer"
+)
+
+// defaultConcurrency is the number of chunks processed at the same time when not configured
+const defaultConcurrency = 4
+
+// splitChunkBudget returns the maximum content tokens per chunk for the split strategy
+func splitChunkBudget(modelID string, sysMsg string, chunking config.ChunkingConfig, quality config.QualityConfig) int {
+	if chunking.MaxTokens > 0 {
+		sysTokens, _ := tokenizer.Count(modelID, sysMsg)
+		return chunking.MaxTokens - sysTokens
+	}
+	maxTokens := quality.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = models.DefaultMaxTokens
+	}
+	// The output of each chunk is about as long as its input and must fit in max_tokens
+	return maxTokens / 2
+}
+
+// splitTransform splits the content into heading/paragraph chunks, runs the operation on
+// each chunk with bounded concurrency, and reassembles the results in order
+func splitTransform(ctx context.Context, c *AIController, cfg config.Config, transformConfig *TransformConfig, content string, templateVars map[string]string, chunkBudget int, logger *slog.Logger) (string, error) {
+	count := func(s string) int {
+		n, _ := tokenizer.Count(c.modelID, s)
+		return n
+	}
+	chunks := splitChunks(content, chunkBudget, count)
+
+	concurrency := transformConfig.Chunking.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	logger.Info("content exceeds the chunk budget, processing in chunks",
+		"budget", chunkBudget,
+		"chunks", len(chunks),
+		"concurrency", concurrency)
+
+	contextTemplate := transformConfig.Chunking.ContextMessage
+	if contextTemplate.Template == "" {
+		contextTemplate.Template = config.DefaultChunkContextMessage
+	}
+
+	// Render every message first so that template errors stop the run before any API call
+	userMsgs := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		vars := copyVars(templateVars)
+		vars["Content"] = chunk
+		userMsg, err := transformConfig.UserMessage.Apply(vars)
+		if err != nil {
+			return "", fmt.Errorf("fail in creating user message: %v", err)
+		}
+
+		vars["Part"] = strconv.Itoa(i + 1)
+		vars["Parts"] = strconv.Itoa(len(chunks))
+		vars["Headings"] = strings.Join(neighbouringHeadings(chunks, i), "\n")
+		contextMsg, err := contextTemplate.Apply(vars)
+		if err != nil {
+			return "", fmt.Errorf("fail in creating chunk context message: %v", err)
+		}
+		userMsgs[i] = userMsg + "\n\n" + contextMsg
+	}
+
+	// The first failure cancels the other chunks, so that they stop spending on a result that is discarded
+	chunkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]string, len(chunks))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i := range chunks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if chunkCtx.Err() != nil {
+				return
+			}
+
+			logger.Info("processing chunk", "chunk", i+1, "of", len(chunks))
+			result, err := complete(chunkCtx, c, transformConfig.SystemMessage, userMsgs[i], cfg.Default.Quality)
+			if err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("fail in processing chunk %d: %w", i+1, err)
+					cancel()
+				})
+				return
+			}
+			// The result takes the place of the chunk's text, between the blank lines around it
+			start, end := textBounds(chunks[i])
+			resultStart, resultEnd := textBounds(result)
+			results[i] = chunks[i][:start] + result[resultStart:resultEnd] + chunks[i][end:]
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	joined := strings.Join(results, "")
+	if !strings.HasSuffix(joined, "\n") {
+		joined += "\n"
+	}
+	return joined, nil
+}
+
+// textBounds returns the offsets of the text of s, without the blank lines before it and the whitespace after it
+func textBounds(s string) (int, int) {
+	end := len(strings.TrimRight(s, " \t\r\n"))
+	start := 0
+	for start < end {
+		i := strings.IndexByte(s[start:end], '\n')
+		if i < 0 || strings.TrimSpace(s[start:start+i]) != "" {
+			break
+		}
+		start += i + 1
+	}
+	return start, end
+}
+
+// neighbouringHeadings returns the document title, the heading the chunk starts under,
+// the headings inside the chunk and the first heading after it
+func neighbouringHeadings(chunks []string, index int) []string {
+	var headings []string
+	seen := map[string]bool{}
+	add := func(h string) {
+		if h != "" && !seen[h] {
+			seen[h] = true
+			headings = append(headings, h)
+		}
+	}
+
+	var before []string
+	for _, chunk := range chunks[:index] {
+		before = append(before, headingLines(chunk)...)
+	}
+	if len(before) > 0 {
+		add(before[0])
+		add(before[len(before)-1])
+	}
+	for _, h := range headingLines(chunks[index]) {
+		add(h)
+	}
+	for _, chunk := range chunks[index+1:] {
+		if after := headingLines(chunk); len(after) > 0 {
+			add(after[0])
+			break
+		}
+	}
+
+	if len(headings) == 0 {
+		return []string{"(none)"}
+	}
+	return headings
+}
+
+// headingLines returns the top-level headings of the content in ATX form
+func headingLines(content string) []string {
+	var headings []string
+	for _, h := range markdown.Parse(content).Headings() {
+		headings = append(headings, strings.Repeat("#", h.Level)+" "+h.Text)
+	}
+	return headings
+}
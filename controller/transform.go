@@ -4,6 +4,7 @@ Copyright © 2025 koooyooo
 package controller
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -11,23 +12,24 @@ import (
 	"strings"
 
 	"github.com/koooyooo/mdai/config"
+	"github.com/koooyooo/mdai/tokenizer"
 	"github.com/koooyooo/mdai/util/file"
-	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 )
 
 // TransformConfig holds configuration for a specific transformation
 type TransformConfig struct {
+	Operation      string
 	SystemMessage  string
 	UserMessage    config.UserMessageTemplate
 	SuffixTemplate config.UserMessageTemplate
+	Chunking       config.ChunkingConfig
 	ExtraArgs      []string
 }
 
 // Transform performs a transformation operation on a markdown file
-func Transform(cfg config.Config, operation string, path string, extraArgs []string, logger *slog.Logger) error {
+func Transform(ctx context.Context, cfg config.Config, operation string, path string, extraArgs []string, logger *slog.Logger) error {
 	// Get operation configuration dynamically
-	opConfig, err := getOperationConfig(cfg, operation)
+	cfg, opConfig, err := getOperationConfig(cfg, operation)
 	if err != nil {
 		return err
 	}
@@ -39,24 +41,20 @@ func Transform(cfg config.Config, operation string, path string, extraArgs []str
 
 	// Create transform configuration
 	transformConfig := &TransformConfig{
+		Operation:      operation,
 		SystemMessage:  opConfig.SystemMessage,
 		UserMessage:    opConfig.UserMessage,
 		SuffixTemplate: opConfig.Suffix,
+		Chunking:       opConfig.Chunking,
 		ExtraArgs:      extraArgs,
 	}
 
 	// Execute transformation
-	return executeTransform(cfg, transformConfig, path, extraArgs, logger)
+	return executeTransform(ctx, cfg, transformConfig, path, extraArgs, logger)
 }
 
-func getOperationConfig(cfg config.Config, operation string) (config.OperationConfig, error) {
-	// Get operation configuration from map
-	opConfig, exists := cfg.Transform.Operations[operation]
-	if !exists {
-		return config.OperationConfig{}, fmt.Errorf("unsupported operation: %s", operation)
-	}
-
-	return opConfig, nil
+func getOperationConfig(cfg config.Config, operation string) (config.Config, config.OperationConfig, error) {
+	return lookupOperation(cfg, cfg.Transform.Operations, "transform", operation)
 }
 
 func validateArgs(extraArgs []string, argsConfig config.ArgsConfig) error {
@@ -73,7 +71,7 @@ func validateArgs(extraArgs []string, argsConfig config.ArgsConfig) error {
 	return nil
 }
 
-func executeTransform(cfg config.Config, transformConfig *TransformConfig, path string, extraArgs []string, logger *slog.Logger) error {
+func executeTransform(ctx context.Context, cfg config.Config, transformConfig *TransformConfig, path string, extraArgs []string, logger *slog.Logger) error {
 	// Validate file
 	if err := validateFile(path); err != nil {
 		return err
@@ -92,38 +90,75 @@ func executeTransform(cfg config.Config, transformConfig *TransformConfig, path
 		return fmt.Errorf("fail in loading content: %v", err)
 	}
 
-	// Prepare messages
-	sysMsg, userMsg, err := prepareMessages(cfg, transformConfig, content, extraArgs)
+	result, err := generateTransform(ctx, cfg, transformConfig, path, content, extraArgs, logger)
 	if err != nil {
-		return err
+		return fmt.Errorf("fail in executing transformation: %w", err)
 	}
 
-	// Execute transformation
-	client := openai.NewClient(option.WithAPIKey(os.Getenv("OPENAI_API_KEY")))
-	openAIController := NewOpenAIController(&client, cfg.Default.Model, logger)
+	// Save result to file
+	written, discard, err := snapshot(cfg, outputPath, transformConfig.Operation)
+	if err != nil {
+		return err
+	}
+	if err := saveResult(outputPath, result, path, extraArgs); err != nil {
+		_ = discard()
+		return fmt.Errorf("fail in saving result: %v", err)
+	}
+	if err := written(); err != nil {
+		return err
+	}
 
-	// Log configuration values
-	logger.Info("using configuration",
-		"maxTokens", cfg.Default.Quality.MaxTokens,
-		"temperature", cfg.Default.Quality.Temperature)
+	logger.Info("transformation completed successfully",
+		"input", path,
+		"output", outputPath)
 
-	if err := openAIController.Control(sysMsg, userMsg, cfg.Default.Quality, func(completion *openai.ChatCompletion) error {
-		result := completion.Choices[0].Message.Content
+	return nil
+}
 
-		// Save result to file
-		if err := saveResult(outputPath, result, path, extraArgs); err != nil {
-			return fmt.Errorf("fail in saving result: %v", err)
-		}
+// generateTransform runs the operation on the content, in chunks when the chunking strategy requires it
+func generateTransform(ctx context.Context, cfg config.Config, transformConfig *TransformConfig, path, content string, extraArgs []string, logger *slog.Logger) (string, error) {
+	// Prepare messages
+	sysMsg, userMsg, err := prepareMessages(cfg, transformConfig, content, extraArgs)
+	if err != nil {
+		return "", err
+	}
 
-		logger.Info("transformation completed successfully",
-			"input", path,
-			"output", outputPath)
-		return nil
-	}); err != nil {
-		return fmt.Errorf("fail in executing transformation: %v", err)
+	// Execute transformation
+	p, err := newProvider(cfg.Default)
+	if err != nil {
+		return "", err
+	}
+	ledger, err := newLedger(cfg)
+	if err != nil {
+		return "", err
 	}
+	aiController := NewAIController(p, cfg.Default.Model, logger).WithBudget(cfg.Budget).WithRetry(cfg.Default.Retry).WithLedger(ledger, transformConfig.Operation, path)
+
+	logConfiguration(logger, transformConfig.Operation, cfg.Default)
+
+	var result string
+	switch chunking := transformConfig.Chunking; {
+	case chunking.Strategy == config.ChunkingMapReduce && exceedsPromptLimit(cfg, chunking, sysMsg, userMsg):
+		limit := promptLimit(cfg.Default.Model, chunking, cfg.Default.Quality)
+		result, err = mapReduce(ctx, aiController, cfg, transformConfig, content, transformTemplateVars(content, extraArgs), limit, 1, logger)
+	case chunking.Strategy == config.ChunkingSplit && exceedsChunkBudget(cfg, chunking, sysMsg, content):
+		budget := splitChunkBudget(cfg.Default.Model, sysMsg, chunking, cfg.Default.Quality)
+		result, err = splitTransform(ctx, aiController, cfg, transformConfig, content, transformTemplateVars(content, extraArgs), budget, logger)
+	default:
+		result, err = complete(ctx, aiController, sysMsg, userMsg, cfg.Default.Quality)
+	}
+	return result, err
+}
 
-	return nil
+func exceedsPromptLimit(cfg config.Config, chunking config.ChunkingConfig, sysMsg, userMsg string) bool {
+	limit := promptLimit(cfg.Default.Model, chunking, cfg.Default.Quality)
+	return limit > 0 && countPromptTokens(cfg.Default.Model, sysMsg, userMsg) > limit
+}
+
+func exceedsChunkBudget(cfg config.Config, chunking config.ChunkingConfig, sysMsg, content string) bool {
+	budget := splitChunkBudget(cfg.Default.Model, sysMsg, chunking, cfg.Default.Quality)
+	tokens, _ := tokenizer.Count(cfg.Default.Model, content)
+	return budget > 0 && tokens > budget
 }
 
 func validateFile(path string) error {
@@ -142,12 +177,7 @@ func validateFile(path string) error {
 
 func generateSuffix(suffixTemplate config.UserMessageTemplate, extraArgs []string) (string, error) {
 	// Prepare template variables
-	templateVars := map[string]string{}
-
-	// Add extra arguments as Arg0, Arg1, etc.
-	for i, arg := range extraArgs {
-		templateVars[fmt.Sprintf("Arg%d", i)] = arg
-	}
+	templateVars := argTemplateVars(map[string]string{}, extraArgs)
 
 	// Apply template processing
 	suffix, err := suffixTemplate.Apply(templateVars)
@@ -167,11 +197,22 @@ func generateOutputPath(inputPath, suffix string) string {
 
 func prepareMessages(cfg config.Config, transformConfig *TransformConfig, content string, extraArgs []string) (string, string, error) {
 	sysMsg := transformConfig.SystemMessage
+	templateVars := transformTemplateVars(content, extraArgs)
+
+	// Apply template processing
+	userMsg, err := transformConfig.UserMessage.Apply(templateVars)
+	if err != nil {
+		return "", "", fmt.Errorf("fail in creating user message: %v", err)
+	}
+
+	return sysMsg, userMsg, nil
+}
 
+func transformTemplateVars(content string, extraArgs []string) map[string]string {
 	// Prepare template variables
-	templateVars := map[string]string{
+	templateVars := argTemplateVars(map[string]string{
 		"Content": content,
-	}
+	}, extraArgs)
 
 	// Add operation-specific template variables based on extraArgs
 	// This allows each operation to define its own template variables
@@ -182,13 +223,15 @@ func prepareMessages(cfg config.Config, transformConfig *TransformConfig, conten
 		}
 	}
 
-	// Apply template processing
-	userMsg, err := transformConfig.UserMessage.Apply(templateVars)
-	if err != nil {
-		return "", "", fmt.Errorf("fail in creating user message: %v", err)
-	}
+	return templateVars
+}
 
-	return sysMsg, userMsg, nil
+// argTemplateVars adds the extra arguments to the template variables as Arg0, Arg1, etc.
+func argTemplateVars(templateVars map[string]string, extraArgs []string) map[string]string {
+	for i, arg := range extraArgs {
+		templateVars[fmt.Sprintf("Arg%d", i)] = arg
+	}
+	return templateVars
 }
 
 func saveResult(outputPath, result, originalPath string, extraArgs []string) error {
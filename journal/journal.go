@@ -0,0 +1,344 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/koooyooo/mdai/util/file"
+)
+
+// Entry is a snapshot of a file taken before mdai modified it
+type Entry struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Operation string    `json:"operation"`
+	Path      string    `json:"path"`
+	// Exists is false when the file was created by the write; undoing it removes the file
+	Exists bool `json:"exists"`
+	// Hash is the SHA-256 of the pre-image, which is stored under objects/<hash>
+	Hash string `json:"hash,omitempty"`
+	Size int64  `json:"size"`
+	// PostHash is the SHA-256 of the content mdai wrote; empty when the write was not completed
+	PostHash string `json:"post_hash,omitempty"`
+}
+
+// ErrModified is returned by Undo when the file changed after mdai wrote it
+var ErrModified = errors.New("file modified after the mdai write")
+
+// ErrIncomplete is returned by Undo when the write of the entry was not completed,
+// so that the current content of the file cannot be told apart from later edits
+var ErrIncomplete = errors.New("mdai write not completed")
+
+// Journal keeps the pre-images of modified files in a directory:
+// an index of entries (journal.jsonl) and the contents addressed by their hash (objects/)
+type Journal struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// New creates a journal stored in the given directory
+func New(dir string) *Journal {
+	return &Journal{dir: dir}
+}
+
+// DefaultDir returns the default journal directory (~/.mdai/journal)
+func DefaultDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, ".mdai", "journal"), nil
+}
+
+// Dir returns the journal directory
+func (j *Journal) Dir() string {
+	return j.dir
+}
+
+func (j *Journal) indexPath() string {
+	return filepath.Join(j.dir, "journal.jsonl")
+}
+
+func (j *Journal) objectPath(hash string) string {
+	return filepath.Join(j.dir, "objects", hash)
+}
+
+// Record takes a snapshot of the file before the operation writes it
+func (j *Journal) Record(path, operation string) (Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to resolve path: %v", err)
+	}
+	now := time.Now()
+	entry := Entry{
+		ID:        fmt.Sprintf("%d", now.UnixNano()),
+		Timestamp: now,
+		Operation: operation,
+		Path:      abs,
+	}
+
+	content, err := os.ReadFile(abs)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return Entry{}, fmt.Errorf("failed to read file: %v", err)
+	default:
+		sum := sha256.Sum256(content)
+		entry.Exists = true
+		entry.Hash = hex.EncodeToString(sum[:])
+		entry.Size = int64(len(content))
+		if err := j.storeObject(entry.Hash, content); err != nil {
+			return Entry{}, err
+		}
+	}
+
+	if err := j.appendEntry(entry); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// Complete records the content the operation wrote to the file of the entry,
+// so that an undo can tell whether the file was modified since
+func (j *Journal) Complete(entry Entry) error {
+	hash, err := fileHash(entry.Path)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries, err := j.load()
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		if entries[i].ID == entry.ID {
+			entries[i].PostHash = hash
+		}
+	}
+	return j.save(entries)
+}
+
+// Discard removes the entry of a write that did not take place
+func (j *Journal) Discard(entry Entry) error {
+	return j.remove(entry.ID)
+}
+
+// fileHash returns the SHA-256 of the file, or an empty string when it does not exist
+func fileHash(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (j *Journal) storeObject(hash string, content []byte) error {
+	path := j.objectPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %v", err)
+	}
+	if err := file.WriteFileAtomic(path, content); err != nil {
+		return fmt.Errorf("failed to store snapshot: %v", err)
+	}
+	return nil
+}
+
+func (j *Journal) appendEntry(entry Entry) error {
+	if err := os.MkdirAll(j.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %v", err)
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %v", err)
+	}
+
+	f, err := os.OpenFile(j.indexPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal entry: %v", err)
+	}
+	return nil
+}
+
+// Entries returns the entries in the order they were recorded, limited to the file when path is not empty.
+// A missing journal has no entries.
+func (j *Journal) Entries(path string) ([]Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.load()
+	if err != nil || path == "" {
+		return entries, err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %v", err)
+	}
+	var filtered []Entry
+	for _, e := range entries {
+		if e.Path == abs {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered, nil
+}
+
+func (j *Journal) load() ([]Entry, error) {
+	f, err := os.Open(j.indexPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("failed to parse journal line %d: %v", lineNo, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %v", err)
+	}
+	return entries, nil
+}
+
+// Undo restores the file of the latest entry, limited to the file when path is not empty,
+// and removes the entry so that the next undo goes one step further back.
+// A file modified after the mdai write is restored only when force is set, since the changes would be lost.
+func (j *Journal) Undo(path string, force bool) (Entry, error) {
+	entries, err := j.Entries(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	if len(entries) == 0 {
+		return Entry{}, fmt.Errorf("no history to undo")
+	}
+	entry := entries[len(entries)-1]
+
+	if !force {
+		if err := checkWritten(entry); err != nil {
+			return Entry{}, err
+		}
+	}
+	if err := j.Restore(entry); err != nil {
+		return Entry{}, err
+	}
+	if err := j.remove(entry.ID); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// checkWritten makes sure the file still holds what mdai wrote. A removed file has nothing to lose.
+func checkWritten(entry Entry) error {
+	hash, err := fileHash(entry.Path)
+	if err != nil {
+		return err
+	}
+	switch {
+	case hash == "":
+		return nil
+	case entry.PostHash == "":
+		return fmt.Errorf("%w: %s", ErrIncomplete, entry.Path)
+	case hash != entry.PostHash:
+		return fmt.Errorf("%w: %s", ErrModified, entry.Path)
+	}
+	return nil
+}
+
+// Restore writes the pre-image of the entry back to its file, or removes a file the write created
+func (j *Journal) Restore(entry Entry) error {
+	if !entry.Exists {
+		if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove file: %v", err)
+		}
+		return nil
+	}
+
+	content, err := os.ReadFile(j.objectPath(entry.Hash))
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != entry.Hash {
+		return fmt.Errorf("snapshot of %s is corrupted", entry.Path)
+	}
+	if err := file.WriteFileAtomic(entry.Path, content); err != nil {
+		return fmt.Errorf("failed to restore file: %v", err)
+	}
+	return nil
+}
+
+// remove rewrites the index without the entry. Objects are kept, since other entries may share them.
+func (j *Journal) remove(id string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.load()
+	if err != nil {
+		return err
+	}
+	var kept []Entry
+	for _, e := range entries {
+		if e.ID != id {
+			kept = append(kept, e)
+		}
+	}
+	return j.save(kept)
+}
+
+// save rewrites the index with the entries
+func (j *Journal) save(entries []Entry) error {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode journal entry: %v", err)
+		}
+		buf.Write(append(b, '\n'))
+	}
+	if err := file.WriteFileAtomic(j.indexPath(), buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to update journal: %v", err)
+	}
+	return nil
+}
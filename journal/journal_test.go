@@ -0,0 +1,96 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUndo(t *testing.T) {
+	tests := []struct {
+		name     string
+		complete bool
+		edit     bool
+		force    bool
+		wantErr  error
+	}{
+		{name: "completed write", complete: true},
+		{name: "edited after the write", complete: true, edit: true, wantErr: ErrModified},
+		{name: "edited and forced", complete: true, edit: true, force: true},
+		{name: "write not completed", wantErr: ErrIncomplete},
+		{name: "write not completed and forced", force: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			j := New(filepath.Join(dir, "journal"))
+			path := filepath.Join(dir, "notes.md")
+			if err := os.WriteFile(path, []byte("before\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			entry, err := j.Record(path, "answer")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte("written\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.complete {
+				if err := j.Complete(entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.edit {
+				if err := os.WriteFile(path, []byte("edited\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err = j.Undo(path, tt.force)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Undo() = %v, want %v", err, tt.wantErr)
+			}
+			got, _ := os.ReadFile(path)
+			if tt.wantErr == nil && string(got) != "before\n" {
+				t.Errorf("content after undo = %q", got)
+			}
+			if tt.wantErr != nil && string(got) == "before\n" {
+				t.Errorf("refused undo restored the file")
+			}
+		})
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	dir := t.TempDir()
+	j := New(filepath.Join(dir, "journal"))
+	path := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(path, []byte("before\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	kept, err := j.Record(path, "answer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	discarded, err := j.Record(path, "answer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Discard(discarded); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := j.Entries(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != kept.ID {
+		t.Errorf("entries = %+v, want only %s", entries, kept.ID)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package markdown
+
+// Kind represents the type of a block
+type Kind string
+
+const (
+	KindFrontMatter   Kind = "front_matter"
+	KindHeading       Kind = "heading"
+	KindParagraph     Kind = "paragraph"
+	KindCodeBlock     Kind = "code_block"
+	KindBlockQuote    Kind = "block_quote"
+	KindList          Kind = "list"
+	KindHTML          Kind = "html"
+	KindThematicBreak Kind = "thematic_break"
+)
+
+// Block is a block-level node of a markdown document.
+// Positions always refer to the original source, also for the children of a block quote.
+type Block struct {
+	Kind Kind
+	// Level is the heading level (1-6)
+	Level int
+	// Info is the info string of a fenced code block (e.g. the language)
+	Info string
+	// Fenced reports whether a code block is fenced (as opposed to indented)
+	Fenced bool
+	// Text is the content without markdown syntax: the heading text, the code,
+	// or the lines of a block quote without the quote markers
+	Text string
+	// StartLine and EndLine are 0-based line numbers; EndLine is exclusive
+	StartLine int
+	EndLine   int
+	// Offset and End are byte offsets in the source; End includes the line ending
+	Offset int
+	End    int
+	// Children are the blocks inside a block quote
+	Children []*Block
+}
+
+// Document is a parsed markdown document
+type Document struct {
+	Source string
+	Blocks []*Block
+}
+
+// Raw returns the source text of the block, including markdown syntax
+func (d *Document) Raw(b *Block) string {
+	return d.Source[b.Offset:b.End]
+}
+
+// FrontMatter returns the front matter block, or nil when the document has none
+func (d *Document) FrontMatter() *Block {
+	if len(d.Blocks) > 0 && d.Blocks[0].Kind == KindFrontMatter {
+		return d.Blocks[0]
+	}
+	return nil
+}
+
+// Headings returns the top-level headings in document order
+func (d *Document) Headings() []*Block {
+	var headings []*Block
+	for _, b := range d.Blocks {
+		if b.Kind == KindHeading {
+			headings = append(headings, b)
+		}
+	}
+	return headings
+}
+
+// BlockQuotes returns the top-level block quotes in document order
+func (d *Document) BlockQuotes() []*Block {
+	var quotes []*Block
+	for _, b := range d.Blocks {
+		if b.Kind == KindBlockQuote {
+			quotes = append(quotes, b)
+		}
+	}
+	return quotes
+}
+
+// Sections splits the source before each top-level heading.
+// The front matter forms a section of its own, and concatenating the sections
+// reproduces the source.
+func (d *Document) Sections() []string {
+	var cuts []int
+	for _, b := range d.Blocks {
+		switch b.Kind {
+		case KindHeading:
+			cuts = append(cuts, b.Offset)
+		case KindFrontMatter:
+			cuts = append(cuts, b.End)
+		}
+	}
+	return cutSource(d.Source, cuts)
+}
+
+// Paragraphs splits the source after the blank lines between top-level blocks.
+// Concatenating the paragraphs reproduces the source.
+func (d *Document) Paragraphs() []string {
+	var cuts []int
+	for i, b := range d.Blocks {
+		if i > 0 && b.StartLine > d.Blocks[i-1].EndLine {
+			cuts = append(cuts, b.Offset)
+		}
+	}
+	return cutSource(d.Source, cuts)
+}
+
+func cutSource(source string, cuts []int) []string {
+	var parts []string
+	prev := 0
+	for _, cut := range cuts {
+		if cut <= prev || cut >= len(source) {
+			continue
+		}
+		parts = append(parts, source[prev:cut])
+		prev = cut
+	}
+	if prev < len(source) {
+		parts = append(parts, source[prev:])
+	}
+	return parts
+}
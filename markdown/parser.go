@@ -0,0 +1,403 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	atxHeadingRegexp    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextRegexp        = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	thematicBreakRegexp = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceRegexp         = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})(.*)$")
+	blockQuoteRegexp    = regexp.MustCompile(`^ {0,3}> ?`)
+	listItemRegexp      = regexp.MustCompile(`^ {0,3}(?:[-+*]|\d{1,9}[.)])(?:[ \t]|$)`)
+	htmlCommentRegexp   = regexp.MustCompile(`^ {0,3}<!--`)
+	htmlTagRegexp       = regexp.MustCompile(`^ {0,3}</?[A-Za-z][A-Za-z0-9-]*(?:[ \t>/]|$)`)
+)
+
+// line is a source line seen through the containers (block quotes) it belongs to
+type line struct {
+	// text is the content after container markers, without the line ending
+	text string
+	// start and end are byte offsets of the whole source line; end includes the line ending
+	start int
+	end   int
+	num   int
+}
+
+func (l line) blank() bool {
+	return strings.TrimSpace(l.text) == ""
+}
+
+// Parse parses markdown source into a block-level document
+func Parse(source string) *Document {
+	return &Document{
+		Source: source,
+		Blocks: parseBlocks(splitLines(source), true),
+	}
+}
+
+func splitLines(source string) []line {
+	var lines []line
+	offset := 0
+	for num := 0; offset < len(source); num++ {
+		end := strings.IndexByte(source[offset:], '\n')
+		if end < 0 {
+			end = len(source)
+		} else {
+			end += offset + 1
+		}
+		text := strings.TrimRight(source[offset:end], "\r\n")
+		lines = append(lines, line{text: text, start: offset, end: end, num: num})
+		offset = end
+	}
+	return lines
+}
+
+func newBlock(kind Kind, lines []line) *Block {
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		texts[i] = l.text
+	}
+	return &Block{
+		Kind:      kind,
+		Text:      strings.Join(texts, "\n"),
+		StartLine: lines[0].num,
+		EndLine:   lines[len(lines)-1].num + 1,
+		Offset:    lines[0].start,
+		End:       lines[len(lines)-1].end,
+	}
+}
+
+func parseBlocks(lines []line, allowFrontMatter bool) []*Block {
+	var blocks []*Block
+	i := 0
+
+	if allowFrontMatter {
+		if n := frontMatterLen(lines); n > 0 {
+			b := newBlock(KindFrontMatter, lines[:n])
+			b.Text = joinTexts(lines[1 : n-1])
+			blocks = append(blocks, b)
+			i = n
+		}
+	}
+
+	for i < len(lines) {
+		l := lines[i]
+		switch {
+		case l.blank():
+			i++
+		case fenceRegexp.MatchString(l.text):
+			n := fencedCodeLen(lines[i:])
+			b := newBlock(KindCodeBlock, lines[i:i+n])
+			m := fenceRegexp.FindStringSubmatch(l.text)
+			b.Fenced = true
+			b.Info = strings.TrimSpace(m[3])
+			inner := lines[i+1 : i+n]
+			if n > 1 && isFenceClose(lines[i+n-1].text, m[2]) {
+				inner = lines[i+1 : i+n-1]
+			}
+			b.Text = joinTexts(inner)
+			blocks = append(blocks, b)
+			i += n
+		case atxHeadingRegexp.MatchString(l.text):
+			m := atxHeadingRegexp.FindStringSubmatch(l.text)
+			b := newBlock(KindHeading, lines[i:i+1])
+			b.Level = len(m[1])
+			b.Text = strings.TrimSpace(m[2])
+			blocks = append(blocks, b)
+			i++
+		case thematicBreakRegexp.MatchString(l.text):
+			blocks = append(blocks, newBlock(KindThematicBreak, lines[i:i+1]))
+			i++
+		case blockQuoteRegexp.MatchString(l.text):
+			n := blockQuoteLen(lines[i:])
+			b := newBlock(KindBlockQuote, lines[i:i+n])
+			inner := stripQuote(lines[i : i+n])
+			b.Text = joinTexts(inner)
+			b.Children = parseBlocks(inner, false)
+			blocks = append(blocks, b)
+			i += n
+		case htmlCommentRegexp.MatchString(l.text):
+			n := htmlCommentLen(lines[i:])
+			blocks = append(blocks, newBlock(KindHTML, lines[i:i+n]))
+			i += n
+		case htmlTagRegexp.MatchString(l.text):
+			n := untilBlankLen(lines[i:])
+			blocks = append(blocks, newBlock(KindHTML, lines[i:i+n]))
+			i += n
+		case listItemRegexp.MatchString(l.text):
+			n := listLen(lines[i:])
+			blocks = append(blocks, newBlock(KindList, lines[i:i+n]))
+			i += n
+		case isIndentedCode(l.text):
+			n := indentedCodeLen(lines[i:])
+			b := newBlock(KindCodeBlock, lines[i:i+n])
+			b.Text = dedentCode(lines[i : i+n])
+			blocks = append(blocks, b)
+			i += n
+		default:
+			n, level := paragraphLen(lines[i:])
+			if level > 0 {
+				// Setext heading: the underline is part of the heading block
+				b := newBlock(KindHeading, lines[i:i+n])
+				b.Level = level
+				b.Text = strings.TrimSpace(joinTexts(lines[i : i+n-1]))
+				blocks = append(blocks, b)
+			} else {
+				blocks = append(blocks, newBlock(KindParagraph, lines[i:i+n]))
+			}
+			i += n
+		}
+	}
+	return blocks
+}
+
+func joinTexts(lines []line) string {
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		texts[i] = l.text
+	}
+	return strings.Join(texts, "\n")
+}
+
+// frontMatterLen returns the number of lines of a YAML (---) or TOML (+++) front matter, or 0
+func frontMatterLen(lines []line) int {
+	if len(lines) < 2 {
+		return 0
+	}
+	open := strings.TrimRight(lines[0].text, " \t")
+	if open != "---" && open != "+++" {
+		return 0
+	}
+	for j := 1; j < len(lines); j++ {
+		closing := strings.TrimRight(lines[j].text, " \t")
+		if closing == open || (open == "---" && closing == "...") {
+			return j + 1
+		}
+	}
+	return 0
+}
+
+func fencedCodeLen(lines []line) int {
+	m := fenceRegexp.FindStringSubmatch(lines[0].text)
+	for j := 1; j < len(lines); j++ {
+		if isFenceClose(lines[j].text, m[2]) {
+			return j + 1
+		}
+	}
+	// An unclosed fence runs to the end of the container
+	return len(lines)
+}
+
+func isFenceClose(text, open string) bool {
+	m := fenceRegexp.FindStringSubmatch(text)
+	if m == nil || strings.TrimSpace(m[3]) != "" {
+		return false
+	}
+	return m[2][0] == open[0] && len(m[2]) >= len(open)
+}
+
+// Fence follows fenced code blocks line by line, for scanners working on raw lines
+type Fence struct {
+	open string
+}
+
+// Next reports whether the line belongs to a fenced code block, including the fences themselves
+func (f *Fence) Next(text string) bool {
+	if f.open != "" {
+		if isFenceClose(text, f.open) {
+			f.open = ""
+		}
+		return true
+	}
+	if m := fenceRegexp.FindStringSubmatch(text); m != nil {
+		f.open = m[2]
+		return true
+	}
+	return false
+}
+
+// Closing returns the fence closing the code block left open, or an empty string
+func (f *Fence) Closing() string {
+	return f.open
+}
+
+// blockQuoteLen returns the length of a block quote, including lazy continuation lines:
+// lines without '>' that continue a paragraph left open inside the quote
+func blockQuoteLen(lines []line) int {
+	n := 0
+	for n < len(lines) {
+		l := lines[n]
+		if blockQuoteRegexp.MatchString(l.text) {
+			n++
+			continue
+		}
+		if n > 0 && !l.blank() && !startsBlock(l.text) && endsInParagraph(stripQuote(lines[:n])) {
+			n++
+			continue
+		}
+		break
+	}
+	return n
+}
+
+// stripQuote removes one level of quote markers; lazy lines are kept as they are
+func stripQuote(lines []line) []line {
+	inner := make([]line, len(lines))
+	for i, l := range lines {
+		l.text = blockQuoteRegexp.ReplaceAllString(l.text, "")
+		inner[i] = l
+	}
+	return inner
+}
+
+// endsInParagraph reports whether the lines end inside a paragraph, possibly nested in quotes
+func endsInParagraph(lines []line) bool {
+	blocks := parseBlocks(lines, false)
+	if len(blocks) == 0 {
+		return false
+	}
+	last := blocks[len(blocks)-1]
+	if last.EndLine != lines[len(lines)-1].num+1 {
+		return false
+	}
+	switch last.Kind {
+	case KindParagraph:
+		return true
+	case KindBlockQuote:
+		return endsInParagraph(stripQuote(linesOf(lines, last)))
+	default:
+		return false
+	}
+}
+
+// linesOf returns the lines covered by the block
+func linesOf(lines []line, b *Block) []line {
+	var covered []line
+	for _, l := range lines {
+		if l.num >= b.StartLine && l.num < b.EndLine {
+			covered = append(covered, l)
+		}
+	}
+	return covered
+}
+
+func htmlCommentLen(lines []line) int {
+	for j, l := range lines {
+		if strings.Contains(l.text, "-->") {
+			return j + 1
+		}
+	}
+	return len(lines)
+}
+
+func untilBlankLen(lines []line) int {
+	n := 0
+	for n < len(lines) && !lines[n].blank() {
+		n++
+	}
+	return n
+}
+
+// listLen returns the length of a list: items, their indented or lazy continuation
+// lines, and blank lines followed by another item or indented content
+func listLen(lines []line) int {
+	n := 1
+	for n < len(lines) {
+		l := lines[n]
+		switch {
+		case l.blank():
+			next := n + 1
+			for next < len(lines) && lines[next].blank() {
+				next++
+			}
+			if next < len(lines) && (listItemRegexp.MatchString(lines[next].text) || isIndented(lines[next].text)) {
+				n = next
+				continue
+			}
+			return n
+		case listItemRegexp.MatchString(l.text), isIndented(l.text):
+			n++
+		case startsBlock(l.text):
+			return n
+		default:
+			// Lazy continuation of the item's paragraph
+			n++
+		}
+	}
+	return n
+}
+
+func isIndented(text string) bool {
+	return strings.HasPrefix(text, "  ") || strings.HasPrefix(text, "\t")
+}
+
+func isIndentedCode(text string) bool {
+	return strings.HasPrefix(text, "    ") || strings.HasPrefix(text, "\t")
+}
+
+func indentedCodeLen(lines []line) int {
+	n := 0
+	last := 0
+	for n < len(lines) && (lines[n].blank() || isIndentedCode(lines[n].text)) {
+		n++
+		if !lines[n-1].blank() {
+			last = n
+		}
+	}
+	// Trailing blank lines are not part of the code block
+	return last
+}
+
+func dedentCode(lines []line) string {
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		switch {
+		case strings.HasPrefix(l.text, "\t"):
+			texts[i] = l.text[1:]
+		case strings.HasPrefix(l.text, "    "):
+			texts[i] = l.text[4:]
+		default:
+			texts[i] = strings.TrimLeft(l.text, " ")
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// paragraphLen returns the length of a paragraph and the level of its setext underline, if any
+func paragraphLen(lines []line) (int, int) {
+	n := 1
+	for n < len(lines) {
+		l := lines[n]
+		if l.blank() {
+			return n, 0
+		}
+		if m := setextRegexp.FindStringSubmatch(l.text); m != nil {
+			if m[1][0] == '=' {
+				return n + 1, 1
+			}
+			return n + 1, 2
+		}
+		if startsBlock(l.text) {
+			return n, 0
+		}
+		n++
+	}
+	return n, 0
+}
+
+// startsBlock reports whether the line interrupts a paragraph
+func startsBlock(text string) bool {
+	return atxHeadingRegexp.MatchString(text) ||
+		thematicBreakRegexp.MatchString(text) ||
+		fenceRegexp.MatchString(text) ||
+		blockQuoteRegexp.MatchString(text) ||
+		htmlCommentRegexp.MatchString(text) ||
+		htmlTagRegexp.MatchString(text) ||
+		listItemRegexp.MatchString(text)
+}
@@ -0,0 +1,201 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// summary is the part of a block compared by the tests
+type summary struct {
+	Kind  Kind
+	Level int
+	Text  string
+}
+
+func summarize(blocks []*Block) []summary {
+	var s []summary
+	for _, b := range blocks {
+		s = append(s, summary{Kind: b.Kind, Level: b.Level, Text: b.Text})
+	}
+	return s
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []summary
+	}{
+		{
+			name:   "atx headings and paragraph",
+			source: "# Title #\n\nsome text\nmore text\n\n### Sub\n",
+			want: []summary{
+				{KindHeading, 1, "Title"},
+				{KindParagraph, 0, "some text\nmore text"},
+				{KindHeading, 3, "Sub"},
+			},
+		},
+		{
+			name:   "setext headings",
+			source: "Title\n=====\n\nSub\nline\n---\n",
+			want: []summary{
+				{KindHeading, 1, "Title"},
+				{KindHeading, 2, "Sub\nline"},
+			},
+		},
+		{
+			name:   "thematic break after blank line",
+			source: "text\n\n---\n",
+			want: []summary{
+				{KindParagraph, 0, "text"},
+				{KindThematicBreak, 0, "---"},
+			},
+		},
+		{
+			name:   "front matter",
+			source: "---\ntitle: x\n---\n# H\n",
+			want: []summary{
+				{KindFrontMatter, 0, "title: x"},
+				{KindHeading, 1, "H"},
+			},
+		},
+		{
+			name:   "fenced code hides headings and quotes",
+			source: "```go\n# not a heading\n> not a quote\n```\ntext\n",
+			want: []summary{
+				{KindCodeBlock, 0, "# not a heading\n> not a quote"},
+				{KindParagraph, 0, "text"},
+			},
+		},
+		{
+			name:   "longer closing fence",
+			source: "~~~\n```\n~~~~\n",
+			want: []summary{
+				{KindCodeBlock, 0, "```"},
+			},
+		},
+		{
+			name:   "unclosed fence runs to the end",
+			source: "````\ncode\n```\n# still code\n",
+			want: []summary{
+				{KindCodeBlock, 0, "code\n```\n# still code"},
+			},
+		},
+		{
+			name:   "indented code",
+			source: "    code\n\n    more\n\ntext\n",
+			want: []summary{
+				{KindCodeBlock, 0, "code\n\nmore"},
+				{KindParagraph, 0, "text"},
+			},
+		},
+		{
+			name:   "block quote with lazy line",
+			source: "> quoted\nlazy\n\ntext\n",
+			want: []summary{
+				{KindBlockQuote, 0, "quoted\nlazy"},
+				{KindParagraph, 0, "text"},
+			},
+		},
+		{
+			name:   "lazy line does not continue a heading",
+			source: "> # Heading\nnext\n",
+			want: []summary{
+				{KindBlockQuote, 0, "# Heading"},
+				{KindParagraph, 0, "next"},
+			},
+		},
+		{
+			name:   "heading interrupts a quote",
+			source: "> quoted\n# Heading\n",
+			want: []summary{
+				{KindBlockQuote, 0, "quoted"},
+				{KindHeading, 1, "Heading"},
+			},
+		},
+		{
+			name:   "list with continuation",
+			source: "- a\n  continued\n- b\n\n- c\n\ntext\n",
+			want: []summary{
+				{KindList, 0, "- a\n  continued\n- b\n\n- c"},
+				{KindParagraph, 0, "text"},
+			},
+		},
+		{
+			name:   "answer markers are html comments",
+			source: "<!-- mdai:answer model=\"m\" -->\nanswer\n<!-- /mdai:answer -->\n",
+			want: []summary{
+				{KindHTML, 0, "<!-- mdai:answer model=\"m\" -->"},
+				{KindParagraph, 0, "answer"},
+				{KindHTML, 0, "<!-- /mdai:answer -->"},
+			},
+		},
+		{
+			name:   "multi-line comment",
+			source: "<!--\n# hidden\n-->\n",
+			want: []summary{
+				{KindHTML, 0, "<!--\n# hidden\n-->"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := Parse(tt.source)
+			if got := summarize(doc.Blocks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	source := "# A\r\n\r\n> q1\n> > q2\n"
+	doc := Parse(source)
+	if len(doc.Blocks) != 2 {
+		t.Fatalf("blocks = %d, want 2", len(doc.Blocks))
+	}
+	if got := doc.Raw(doc.Blocks[0]); got != "# A\r\n" {
+		t.Errorf("Raw(heading) = %q", got)
+	}
+	quote := doc.Blocks[1]
+	if quote.StartLine != 2 || quote.EndLine != 4 || doc.Raw(quote) != "> q1\n> > q2\n" {
+		t.Errorf("quote at lines %d-%d: %q", quote.StartLine, quote.EndLine, doc.Raw(quote))
+	}
+	// Children keep positions in the original source
+	nested := quote.Children[1]
+	if nested.Kind != KindBlockQuote || doc.Raw(nested) != "> > q2\n" || nested.Children[0].Text != "q2" {
+		t.Errorf("nested quote = %+v", nested)
+	}
+}
+
+func TestSectionsAndParagraphs(t *testing.T) {
+	source := "---\nk: v\n---\nintro\n\n# One\ntext\n\n## Two\n```\n# code\n```\n"
+	doc := Parse(source)
+
+	sections := doc.Sections()
+	want := []string{"---\nk: v\n---\n", "intro\n\n", "# One\ntext\n\n", "## Two\n```\n# code\n```\n"}
+	if !reflect.DeepEqual(sections, want) {
+		t.Errorf("Sections() = %q, want %q", sections, want)
+	}
+	if got := strings.Join(doc.Paragraphs(), ""); got != source {
+		t.Errorf("Paragraphs() do not reproduce the source: %q", got)
+	}
+}
+
+func TestFence(t *testing.T) {
+	lines := []string{"text", "```md", "<!-- mdai:answer -->", "~~~", "```", "after", "~~~~", "code"}
+	want := []bool{false, true, true, true, true, false, true, true}
+	var f Fence
+	for i, l := range lines {
+		if got := f.Next(l); got != want[i] {
+			t.Errorf("line %d %q: Next() = %v, want %v", i, l, got, want[i])
+		}
+	}
+	if got := f.Closing(); got != "~~~~" {
+		t.Errorf("Closing() = %q, want %q", got, "~~~~")
+	}
+}
@@ -3,6 +3,11 @@ Copyright © 2025 koooyooo
 */
 package models
 
+import (
+	"fmt"
+	"strings"
+)
+
 // ModelType represents the type of AI model
 type ModelType string
 
@@ -21,6 +26,16 @@ const (
 	ProviderGoogle    Provider = "Google"
 )
 
+// ParseProvider converts a case-insensitive provider name into a Provider
+func ParseProvider(name string) (Provider, error) {
+	for _, p := range []Provider{ProviderOpenAI, ProviderAnthropic, ProviderGoogle} {
+		if strings.EqualFold(name, string(p)) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown provider: %s", name)
+}
+
 // AIModel represents the basic information and pricing of an AI model
 type AIModel struct {
 	ID                   string    // Unique identifier for the model
@@ -97,6 +97,43 @@ var (
 		EmbeddingPricePer1M:  0.10,  // $0.10 per 1M tokens
 		Currency:             "USD",
 	}
+
+	// Google Models
+	Gemini15Flash = &AIModel{
+		ID:                   "gemini-1.5-flash",
+		Name:                 "Gemini 1.5 Flash",
+		Provider:             ProviderGoogle,
+		ModelType:            ModelTypeChat,
+		ContextSize:          1048576,
+		MaxTokens:            8192,
+		PromptPricePer1M:     0.075, // $0.075 per 1M tokens
+		CompletionPricePer1M: 0.30,  // $0.30 per 1M tokens
+		Currency:             "USD",
+	}
+
+	Gemini15Pro = &AIModel{
+		ID:                   "gemini-1.5-pro",
+		Name:                 "Gemini 1.5 Pro",
+		Provider:             ProviderGoogle,
+		ModelType:            ModelTypeChat,
+		ContextSize:          2097152,
+		MaxTokens:            8192,
+		PromptPricePer1M:     1.25, // $1.25 per 1M tokens
+		CompletionPricePer1M: 5.00, // $5.00 per 1M tokens
+		Currency:             "USD",
+	}
+
+	Gemini20Flash = &AIModel{
+		ID:                   "gemini-2.0-flash",
+		Name:                 "Gemini 2.0 Flash",
+		Provider:             ProviderGoogle,
+		ModelType:            ModelTypeChat,
+		ContextSize:          1048576,
+		MaxTokens:            8192,
+		PromptPricePer1M:     0.10, // $0.10 per 1M tokens
+		CompletionPricePer1M: 0.40, // $0.40 per 1M tokens
+		Currency:             "USD",
+	}
 )
 
 // Constants for chat completion configuration
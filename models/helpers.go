@@ -5,8 +5,6 @@ package models
 
 import (
 	"fmt"
-
-	"github.com/openai/openai-go"
 )
 
 // GetModelByID retrieves model information from model ID
@@ -24,6 +22,12 @@ func GetModelByID(modelID string) (*AIModel, error) {
 		return Claude3Sonnet, nil
 	case "claude-3-opus-20240229":
 		return Claude3Opus, nil
+	case "gemini-1.5-flash":
+		return Gemini15Flash, nil
+	case "gemini-1.5-pro":
+		return Gemini15Pro, nil
+	case "gemini-2.0-flash":
+		return Gemini20Flash, nil
 	default:
 		return nil, fmt.Errorf("model not found: %s", modelID)
 	}
@@ -38,16 +42,17 @@ func CalculateCost(modelID string, promptTokens, completionTokens int) (float64,
 	return model.CalculateTotalCost(promptTokens, completionTokens), nil
 }
 
-// CalculateCostString returns cost in a format compatible with existing util/cost package
-func CalculateCostString(modelID string, usage openai.CompletionUsage) (string, error) {
+// CalculateCostString returns cost in a format compatible with existing util/cost package.
+// Models missing from the catalog are reported with unknown cost.
+func CalculateCostString(modelID string, promptTokens, completionTokens int) (string, error) {
 	model, err := GetModelByID(modelID)
 	if err != nil {
-		return "", err
+		return fmt.Sprintf("[%s] unknown cost (Input: %d tokens, Output: %d tokens)", modelID, promptTokens, completionTokens), nil
 	}
 
-	promptCost := model.CalculatePromptCost(int(usage.PromptTokens))
-	completionCost := model.CalculateCompletionCost(int(usage.CompletionTokens))
-	totalCost := promptCost + completionCost
+	promptCost := model.CalculatePromptCost(promptTokens)
+	completionCost := model.CalculateCompletionCost(completionTokens)
+	totalCost := model.CalculateTotalCost(promptTokens, completionTokens)
 
 	return fmt.Sprintf("[%s] $%.5f (Input: $%.5f, Output: $%.5f)", modelID, totalCost, promptCost, completionCost), nil
 }
@@ -61,5 +66,8 @@ func ListModels() []*AIModel {
 		Claude3Haiku,
 		Claude3Sonnet,
 		Claude3Opus,
+		Gemini15Flash,
+		Gemini15Pro,
+		Gemini20Flash,
 	}
 }
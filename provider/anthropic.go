@@ -0,0 +1,261 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/koooyooo/mdai/models"
+)
+
+const (
+	anthropicBaseURL = "https://api.anthropic.com/v1"
+	anthropicVersion = "2023-06-01"
+)
+
+// Anthropic is the provider for the Anthropic Messages API
+type Anthropic struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	apiVersion string
+	headers    map[string]string
+}
+
+// NewAnthropic creates an Anthropic provider authenticated with ANTHROPIC_API_KEY
+func NewAnthropic(opts Options) *Anthropic {
+	p := &Anthropic{
+		httpClient: http.DefaultClient,
+		baseURL:    anthropicBaseURL,
+		apiKey:     opts.apiKey("ANTHROPIC_API_KEY"),
+		apiVersion: anthropicVersion,
+		headers:    opts.Headers,
+	}
+	if opts.BaseURL != "" {
+		p.baseURL = strings.TrimSuffix(opts.BaseURL, "/")
+	}
+	if opts.APIVersion != "" {
+		p.apiVersion = opts.APIVersion
+	}
+	return p
+}
+
+func (p *Anthropic) Name() models.Provider {
+	return models.ProviderAnthropic
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      anthropicUsage `json:"usage"`
+}
+
+type anthropicError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicStreamEvent covers the fields used from every streaming event type
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *Anthropic) Complete(ctx context.Context, req Request) (*Response, error) {
+	resp, err := p.post(ctx, p.newRequest(req, false))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var body anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode Anthropic API response: %v", err)
+	}
+
+	var content strings.Builder
+	for _, block := range body.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	if content.Len() == 0 && body.StopReason == "" {
+		return nil, fmt.Errorf("no response from Anthropic API")
+	}
+
+	return &Response{
+		Content:      content.String(),
+		FinishReason: anthropicFinishReason(body.StopReason),
+		Usage: &Usage{
+			PromptTokens:     body.Usage.InputTokens,
+			CompletionTokens: body.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+func (p *Anthropic) Stream(ctx context.Context, req Request, deltaFunc func(delta string) error) (*Response, error) {
+	resp, err := p.post(ctx, p.newRequest(req, true))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var content strings.Builder
+	var stopReason string
+	usage := &Usage{}
+
+	err = readSSE(models.ProviderAnthropic, resp.Body, func(ev sseEvent) error {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(ev.Data), &event); err != nil {
+			return fmt.Errorf("failed to decode Anthropic stream event: %v", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			usage.PromptTokens = event.Message.Usage.InputTokens
+			usage.CompletionTokens = event.Message.Usage.OutputTokens
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				return nil
+			}
+			content.WriteString(event.Delta.Text)
+			return deltaFunc(event.Delta.Text)
+		case "message_delta":
+			stopReason = event.Delta.StopReason
+			usage.CompletionTokens = event.Usage.OutputTokens
+		case "error":
+			return newStatusError(models.ProviderAnthropic, anthropicErrorStatus(event.Error.Type), nil, event.Error.Type+": "+event.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Content:      content.String(),
+		FinishReason: anthropicFinishReason(stopReason),
+		Usage:        usage,
+	}, nil
+}
+
+func (p *Anthropic) newRequest(req Request, stream bool) anthropicRequest {
+	messages := make([]anthropicMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		messages = append(messages, anthropicMessage{Role: string(m.Role), Content: m.Content})
+	}
+
+	return anthropicRequest{
+		Model:       req.Model,
+		System:      req.System,
+		Messages:    messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stream:      stream,
+	}
+}
+
+func (p *Anthropic) post(ctx context.Context, body anthropicRequest) (*http.Response, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Anthropic API request: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/messages", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", p.apiVersion)
+	for k, v := range p.headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, newNetworkError(models.ProviderAnthropic, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer func() { _ = resp.Body.Close() }()
+		raw, _ := io.ReadAll(resp.Body)
+		var apiErr anthropicError
+		if err := json.Unmarshal(raw, &apiErr); err == nil && apiErr.Error.Message != "" {
+			return nil, newStatusError(models.ProviderAnthropic, resp.StatusCode, resp.Header, apiErr.Error.Type+": "+apiErr.Error.Message)
+		}
+		return nil, newStatusError(models.ProviderAnthropic, resp.StatusCode, resp.Header, strings.TrimSpace(string(raw)))
+	}
+	return resp, nil
+}
+
+// anthropicErrorStatus maps the type of an error event in a stream to its HTTP status
+func anthropicErrorStatus(errorType string) int {
+	switch errorType {
+	case "overloaded_error":
+		return 529
+	case "rate_limit_error":
+		return http.StatusTooManyRequests
+	case "api_error":
+		return http.StatusInternalServerError
+	case "authentication_error":
+		return http.StatusUnauthorized
+	case "permission_error":
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+func anthropicFinishReason(reason string) FinishReason {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return FinishReasonStop
+	case "max_tokens":
+		return FinishReasonLength
+	default:
+		return FinishReasonOther
+	}
+}
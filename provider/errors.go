@@ -0,0 +1,144 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/koooyooo/mdai/models"
+)
+
+// ErrorKind classifies API errors
+type ErrorKind string
+
+const (
+	ErrorKindAuth          ErrorKind = "auth"
+	ErrorKindRateLimit     ErrorKind = "rate_limit"
+	ErrorKindContextLength ErrorKind = "context_length"
+	ErrorKindServer        ErrorKind = "server"
+	ErrorKindNetwork       ErrorKind = "network"
+	ErrorKindRequest       ErrorKind = "request"
+)
+
+// Error is an error returned by a provider API, or a failure to reach it
+type Error struct {
+	Kind     ErrorKind
+	Provider models.Provider
+	// StatusCode is 0 for network errors
+	StatusCode int
+	// RetryAfter is the delay requested by the Retry-After header, 0 when absent
+	RetryAfter time.Duration
+	Message    string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s API error (%s): %s", e.Provider, e.Kind, e.Message)
+	}
+	return fmt.Sprintf("%s API error (%s): %d %s", e.Provider, e.Kind, e.StatusCode, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the request may succeed when it is sent again
+func (e *Error) Retryable() bool {
+	switch e.Kind {
+	case ErrorKindRateLimit, ErrorKindServer, ErrorKindNetwork:
+		return true
+	}
+	return false
+}
+
+// AsError returns the provider error in the chain of err, if any
+func AsError(err error) (*Error, bool) {
+	var apiErr *Error
+	ok := errors.As(err, &apiErr)
+	return apiErr, ok
+}
+
+// newStatusError classifies an error response by its status code and message
+func newStatusError(provider models.Provider, statusCode int, header http.Header, message string) *Error {
+	e := &Error{
+		Provider:   provider,
+		StatusCode: statusCode,
+		Message:    message,
+		RetryAfter: parseRetryAfter(header),
+	}
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden || isAuthMessage(message):
+		e.Kind = ErrorKindAuth
+	case statusCode == http.StatusTooManyRequests:
+		e.Kind = ErrorKindRateLimit
+	case statusCode == http.StatusRequestTimeout || statusCode >= 500:
+		// Anthropic reports overload as 529
+		e.Kind = ErrorKindServer
+	case isContextLengthMessage(message):
+		e.Kind = ErrorKindContextLength
+	default:
+		e.Kind = ErrorKindRequest
+	}
+	return e
+}
+
+// newNetworkError wraps a failure to reach the API. Cancellation is returned as is.
+func newNetworkError(provider models.Provider, err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return &Error{
+		Kind:     ErrorKindNetwork,
+		Provider: provider,
+		Message:  err.Error(),
+		Err:      err,
+	}
+}
+
+// isAuthMessage detects invalid keys reported as bad requests (Gemini)
+func isAuthMessage(message string) bool {
+	return strings.Contains(message, "API_KEY_INVALID") || strings.Contains(message, "API key not valid")
+}
+
+func isContextLengthMessage(message string) bool {
+	message = strings.ToLower(message)
+	for _, s := range []string{
+		"context length",
+		"context_length",
+		"context window",
+		"maximum context",
+		"prompt is too long",
+		"too many tokens",
+		"exceeds the maximum number of tokens",
+	} {
+		if strings.Contains(message, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRetryAfter reads the Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(header http.Header) time.Duration {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
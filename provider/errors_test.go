@@ -0,0 +1,94 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/koooyooo/mdai/models"
+)
+
+func TestNewStatusError(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		message    string
+		kind       ErrorKind
+		retryable  bool
+	}{
+		{"unauthorized", http.StatusUnauthorized, "invalid key", ErrorKindAuth, false},
+		{"forbidden", http.StatusForbidden, "no access", ErrorKindAuth, false},
+		{"gemini invalid key", http.StatusBadRequest, "API key not valid. Please pass a valid API key.", ErrorKindAuth, false},
+		{"rate limit", http.StatusTooManyRequests, "slow down", ErrorKindRateLimit, true},
+		{"timeout", http.StatusRequestTimeout, "timeout", ErrorKindServer, true},
+		{"server", http.StatusInternalServerError, "oops", ErrorKindServer, true},
+		{"anthropic overload", 529, "overloaded_error: Overloaded", ErrorKindServer, true},
+		{"openai context length", http.StatusBadRequest, "context_length_exceeded: This model's maximum context length is 128000 tokens", ErrorKindContextLength, false},
+		{"anthropic context length", http.StatusBadRequest, "invalid_request_error: prompt is too long: 210000 tokens > 200000 maximum", ErrorKindContextLength, false},
+		{"bad request", http.StatusBadRequest, "unknown parameter", ErrorKindRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newStatusError(models.ProviderOpenAI, tt.statusCode, nil, tt.message)
+			if e.Kind != tt.kind {
+				t.Errorf("Kind = %s, want %s", e.Kind, tt.kind)
+			}
+			if e.Retryable() != tt.retryable {
+				t.Errorf("Retryable() = %v, want %v", e.Retryable(), tt.retryable)
+			}
+		})
+	}
+}
+
+func TestNewNetworkError(t *testing.T) {
+	for _, err := range []error{context.Canceled, fmt.Errorf("read: %w", context.DeadlineExceeded)} {
+		if got := newNetworkError(models.ProviderAnthropic, err); got != err {
+			t.Errorf("newNetworkError(%v) = %v, want the cancellation as is", err, got)
+		}
+	}
+
+	err := newNetworkError(models.ProviderAnthropic, io.ErrUnexpectedEOF)
+	apiErr, ok := AsError(fmt.Errorf("fail in streaming: %w", err))
+	if !ok {
+		t.Fatalf("AsError(%v) found no provider error", err)
+	}
+	if apiErr.Kind != ErrorKindNetwork || !apiErr.Retryable() || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("network error = %+v", apiErr)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"0.5", 500 * time.Millisecond},
+		{"-1", 0},
+		{"soon", 0},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.value != "" {
+			header.Set("Retry-After", tt.value)
+		}
+		if got := parseRetryAfter(header); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	header := http.Header{"Retry-After": {future}}
+	if got := parseRetryAfter(header); got <= 0 || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %v, want up to a minute", future, got)
+	}
+}
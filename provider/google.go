@@ -0,0 +1,243 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/koooyooo/mdai/models"
+)
+
+const (
+	googleBaseURL    = "https://generativelanguage.googleapis.com"
+	googleAPIVersion = "v1beta"
+)
+
+// Google is the provider for the Gemini generateContent API
+type Google struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	headers    map[string]string
+}
+
+// NewGoogle creates a Gemini provider authenticated with GEMINI_API_KEY (or GOOGLE_API_KEY)
+func NewGoogle(opts Options) *Google {
+	baseURL := googleBaseURL
+	if opts.BaseURL != "" {
+		baseURL = strings.TrimSuffix(opts.BaseURL, "/")
+	}
+	apiVersion := googleAPIVersion
+	if opts.APIVersion != "" {
+		apiVersion = opts.APIVersion
+	}
+	return &Google{
+		httpClient: http.DefaultClient,
+		baseURL:    baseURL + "/" + apiVersion,
+		apiKey:     opts.apiKey("GEMINI_API_KEY", "GOOGLE_API_KEY"),
+		headers:    opts.Headers,
+	}
+}
+
+func (p *Google) Name() models.Provider {
+	return models.ProviderGoogle
+}
+
+type googlePart struct {
+	Text string `json:"text"`
+}
+
+type googleContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []googlePart `json:"parts"`
+}
+
+type googleGenerationConfig struct {
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+	Temperature     float64 `json:"temperature"`
+}
+
+type googleRequest struct {
+	SystemInstruction *googleContent         `json:"systemInstruction,omitempty"`
+	Contents          []googleContent        `json:"contents"`
+	GenerationConfig  googleGenerationConfig `json:"generationConfig"`
+}
+
+type googleResponse struct {
+	Candidates []struct {
+		Content      googleContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+type googleError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+func (p *Google) Complete(ctx context.Context, req Request) (*Response, error) {
+	resp, err := p.post(ctx, req.Model, "generateContent", nil, p.newRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var body googleResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode Gemini API response: %v", err)
+	}
+	if len(body.Candidates) == 0 {
+		return nil, fmt.Errorf("no response from Gemini API")
+	}
+
+	res := &Response{
+		Content:      googleText(body.Candidates[0].Content),
+		FinishReason: googleFinishReason(body.Candidates[0].FinishReason),
+	}
+	if body.UsageMetadata != nil {
+		res.Usage = &Usage{
+			PromptTokens:     body.UsageMetadata.PromptTokenCount,
+			CompletionTokens: body.UsageMetadata.CandidatesTokenCount,
+		}
+	}
+	return res, nil
+}
+
+func (p *Google) Stream(ctx context.Context, req Request, deltaFunc func(delta string) error) (*Response, error) {
+	resp, err := p.post(ctx, req.Model, "streamGenerateContent", url.Values{"alt": {"sse"}}, p.newRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	res := &Response{}
+	var content strings.Builder
+
+	err = readSSE(models.ProviderGoogle, resp.Body, func(ev sseEvent) error {
+		var chunk googleResponse
+		if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+			return fmt.Errorf("failed to decode Gemini stream chunk: %v", err)
+		}
+
+		// Usage metadata is cumulative, so the last chunk holds the totals
+		if chunk.UsageMetadata != nil {
+			res.Usage = &Usage{
+				PromptTokens:     chunk.UsageMetadata.PromptTokenCount,
+				CompletionTokens: chunk.UsageMetadata.CandidatesTokenCount,
+			}
+		}
+		if len(chunk.Candidates) == 0 {
+			return nil
+		}
+		if chunk.Candidates[0].FinishReason != "" {
+			res.FinishReason = googleFinishReason(chunk.Candidates[0].FinishReason)
+		}
+
+		delta := googleText(chunk.Candidates[0].Content)
+		if delta == "" {
+			return nil
+		}
+		content.WriteString(delta)
+		return deltaFunc(delta)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res.Content = content.String()
+	return res, nil
+}
+
+func (p *Google) newRequest(req Request) googleRequest {
+	contents := make([]googleContent, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		role := "user"
+		if m.Role == RoleAssistant {
+			role = "model"
+		}
+		contents = append(contents, googleContent{Role: role, Parts: []googlePart{{Text: m.Content}}})
+	}
+
+	gr := googleRequest{
+		Contents: contents,
+		GenerationConfig: googleGenerationConfig{
+			MaxOutputTokens: req.MaxTokens,
+			Temperature:     req.Temperature,
+		},
+	}
+	if req.System != "" {
+		gr.SystemInstruction = &googleContent{Parts: []googlePart{{Text: req.System}}}
+	}
+	return gr
+}
+
+func (p *Google) post(ctx context.Context, model, method string, query url.Values, body googleRequest) (*http.Response, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Gemini API request: %v", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/models/%s:%s", p.baseURL, url.PathEscape(model), method)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", p.apiKey)
+	for k, v := range p.headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, newNetworkError(models.ProviderGoogle, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer func() { _ = resp.Body.Close() }()
+		raw, _ := io.ReadAll(resp.Body)
+		var apiErr googleError
+		if err := json.Unmarshal(raw, &apiErr); err == nil && apiErr.Error.Message != "" {
+			return nil, newStatusError(models.ProviderGoogle, resp.StatusCode, resp.Header, apiErr.Error.Status+": "+apiErr.Error.Message)
+		}
+		return nil, newStatusError(models.ProviderGoogle, resp.StatusCode, resp.Header, strings.TrimSpace(string(raw)))
+	}
+	return resp, nil
+}
+
+func googleText(content googleContent) string {
+	var sb strings.Builder
+	for _, part := range content.Parts {
+		sb.WriteString(part.Text)
+	}
+	return sb.String()
+}
+
+func googleFinishReason(reason string) FinishReason {
+	switch reason {
+	case "STOP":
+		return FinishReasonStop
+	case "MAX_TOKENS":
+		return FinishReasonLength
+	default:
+		return FinishReasonOther
+	}
+}
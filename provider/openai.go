@@ -0,0 +1,182 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/koooyooo/mdai/models"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// OpenAI is the provider for the OpenAI Chat Completions API
+type OpenAI struct {
+	client openai.Client
+}
+
+// NewOpenAI creates an OpenAI provider authenticated with OPENAI_API_KEY.
+// A base URL makes it usable with OpenAI-compatible servers (Ollama, vLLM, LM Studio),
+// and an API version switches to Azure OpenAI authentication.
+// OPENAI_API_KEY is only sent to OpenAI itself; other servers get the key of api_key_env, if any.
+func NewOpenAI(opts Options) *OpenAI {
+	// Retries are handled by the caller, which knows whether output has already been written
+	reqOpts := []option.RequestOption{option.WithMaxRetries(0)}
+	if opts.BaseURL != "" || opts.APIVersion != "" {
+		// Drop the OpenAI credentials the client picks up from the environment
+		reqOpts = append(reqOpts,
+			option.WithHeaderDel("Authorization"),
+			option.WithHeaderDel("OpenAI-Organization"),
+			option.WithHeaderDel("OpenAI-Project"),
+		)
+	}
+	switch {
+	case opts.APIVersion != "":
+		// Azure OpenAI: the base URL points to the deployment, e.g.
+		// https://<resource>.openai.azure.com/openai/deployments/<deployment>
+		reqOpts = append(reqOpts, option.WithQuery("api-version", opts.APIVersion))
+		if key := opts.apiKey("AZURE_OPENAI_API_KEY"); key != "" {
+			reqOpts = append(reqOpts, option.WithHeader("Api-Key", key))
+		}
+	case opts.BaseURL != "":
+		if opts.APIKeyEnv != "" {
+			reqOpts = append(reqOpts, option.WithAPIKey(opts.apiKey()))
+		}
+	default:
+		reqOpts = append(reqOpts, option.WithAPIKey(opts.apiKey("OPENAI_API_KEY")))
+	}
+	if opts.BaseURL != "" {
+		reqOpts = append(reqOpts, option.WithBaseURL(opts.BaseURL))
+	}
+	for k, v := range opts.Headers {
+		reqOpts = append(reqOpts, option.WithHeader(k, v))
+	}
+
+	return &OpenAI{
+		client: openai.NewClient(reqOpts...),
+	}
+}
+
+func (p *OpenAI) Name() models.Provider {
+	return models.ProviderOpenAI
+}
+
+func (p *OpenAI) Complete(ctx context.Context, req Request) (*Response, error) {
+	completion, err := p.client.Chat.Completions.New(ctx, p.params(req))
+	if err != nil {
+		return nil, openAIError(err)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("no response from OpenAI API")
+	}
+
+	return &Response{
+		Content:      completion.Choices[0].Message.Content,
+		FinishReason: openAIFinishReason(completion.Choices[0].FinishReason),
+		Usage: &Usage{
+			PromptTokens:     int(completion.Usage.PromptTokens),
+			CompletionTokens: int(completion.Usage.CompletionTokens),
+		},
+	}, nil
+}
+
+func (p *OpenAI) Stream(ctx context.Context, req Request, deltaFunc func(delta string) error) (*Response, error) {
+	params := p.params(req)
+	params.Seed = openai.Int(0)
+	// Request a final chunk carrying the token usage of the whole stream
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openai.Bool(true),
+	}
+	stream := p.client.Chat.Completions.NewStreaming(ctx, params)
+	defer func() { _ = stream.Close() }()
+
+	// optionally, an accumulator helper can be used
+	acc := openai.ChatCompletionAccumulator{}
+
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+
+		// it's best to use chunks after handling JustFinished events
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			if err := deltaFunc(chunk.Choices[0].Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return nil, openAIError(err)
+	}
+	if len(acc.Choices) == 0 {
+		return nil, fmt.Errorf("no response from OpenAI API")
+	}
+
+	res := &Response{
+		Content:      acc.Choices[0].Message.Content,
+		FinishReason: openAIFinishReason(acc.Choices[0].FinishReason),
+	}
+	// Servers ignoring stream_options.include_usage leave the usage empty
+	if acc.Usage.PromptTokens > 0 || acc.Usage.CompletionTokens > 0 {
+		res.Usage = &Usage{
+			PromptTokens:     int(acc.Usage.PromptTokens),
+			CompletionTokens: int(acc.Usage.CompletionTokens),
+		}
+	}
+	return res, nil
+}
+
+// openAIError classifies an error of the OpenAI client
+func openAIError(err error) error {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return newNetworkError(models.ProviderOpenAI, err)
+	}
+	message := apiErr.Message
+	if apiErr.Code != "" {
+		message = apiErr.Code + ": " + message
+	}
+	var header http.Header
+	if apiErr.Response != nil {
+		header = apiErr.Response.Header
+	}
+	e := newStatusError(models.ProviderOpenAI, apiErr.StatusCode, header, message)
+	e.Err = err
+	return e
+}
+
+func (p *OpenAI) params(req Request) openai.ChatCompletionNewParams {
+	messages := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(req.System),
+	}
+	for _, m := range req.Messages {
+		switch m.Role {
+		case RoleAssistant:
+			messages = append(messages, openai.AssistantMessage(m.Content))
+		default:
+			messages = append(messages, openai.UserMessage(m.Content))
+		}
+	}
+
+	return openai.ChatCompletionNewParams{
+		Model:       req.Model,
+		Messages:    messages,
+		MaxTokens:   openai.Int(int64(req.MaxTokens)),
+		Temperature: openai.Float(req.Temperature),
+	}
+}
+
+func openAIFinishReason(reason string) FinishReason {
+	switch reason {
+	case "stop":
+		return FinishReasonStop
+	case "length":
+		return FinishReasonLength
+	default:
+		return FinishReasonOther
+	}
+}
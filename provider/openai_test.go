@@ -0,0 +1,59 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewOpenAIKeys(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "openai-key")
+	t.Setenv("OPENAI_ORG_ID", "org")
+	t.Setenv("AZURE_OPENAI_API_KEY", "azure-key")
+	t.Setenv("MY_KEY", "my-key")
+
+	tests := []struct {
+		name          string
+		opts          Options
+		authorization string
+		apiKey        string
+	}{
+		{"custom base url", Options{}, "", ""},
+		{"custom base url with api_key_env", Options{APIKeyEnv: "MY_KEY"}, "Bearer my-key", ""},
+		{"azure", Options{APIVersion: "2024-06-01"}, "", "azure-key"},
+		{"azure with api_key_env", Options{APIVersion: "2024-06-01", APIKeyEnv: "MY_KEY"}, "", "my-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header.Clone()
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+			}))
+			defer server.Close()
+
+			tt.opts.BaseURL = server.URL
+			res, err := NewOpenAI(tt.opts).Complete(context.Background(), Request{Model: "m", Messages: []Message{{Role: RoleUser, Content: "q"}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Content != "ok" {
+				t.Errorf("Content = %q", res.Content)
+			}
+			if got := header.Get("Authorization"); got != tt.authorization {
+				t.Errorf("Authorization = %q, want %q", got, tt.authorization)
+			}
+			if got := header.Get("Api-Key"); got != tt.apiKey {
+				t.Errorf("Api-Key = %q, want %q", got, tt.apiKey)
+			}
+			if got := header.Get("OpenAI-Organization"); got != "" {
+				t.Errorf("OpenAI-Organization = %q, want none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/koooyooo/mdai/models"
+)
+
+// Role represents the author of a chat message
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// FinishReason represents why the model stopped generating
+type FinishReason string
+
+const (
+	FinishReasonStop   FinishReason = "stop"
+	FinishReasonLength FinishReason = "length"
+	FinishReasonOther  FinishReason = "other"
+)
+
+// Message represents a single chat message
+type Message struct {
+	Role    Role
+	Content string
+}
+
+// Request represents a provider-agnostic chat completion request
+type Request struct {
+	Model       string
+	System      string
+	Messages    []Message
+	MaxTokens   int
+	Temperature float64
+}
+
+// Usage represents the token usage reported by the provider
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Response represents a provider-agnostic chat completion response
+type Response struct {
+	Content      string
+	FinishReason FinishReason
+	// Usage is nil when the provider did not report token usage
+	Usage *Usage
+}
+
+// Provider is the interface implemented by each LLM backend
+type Provider interface {
+	// Name returns the provider name
+	Name() models.Provider
+	// Complete sends a request and waits for the whole response
+	Complete(ctx context.Context, req Request) (*Response, error)
+	// Stream sends a request and calls deltaFunc for each piece of generated content.
+	// The returned response holds the accumulated content and usage.
+	Stream(ctx context.Context, req Request, deltaFunc func(delta string) error) (*Response, error)
+}
+
+// Options represents the connection settings of a provider
+type Options struct {
+	// BaseURL overrides the default API endpoint
+	BaseURL string
+	// Headers are added to every request
+	Headers map[string]string
+	// APIVersion selects the API version (Azure OpenAI api-version, anthropic-version, Gemini API version)
+	APIVersion string
+	// APIKeyEnv overrides the environment variable holding the API key
+	APIKeyEnv string
+}
+
+// apiKey returns the API key from the configured environment variable, falling back to the defaults
+func (o Options) apiKey(defaultEnvs ...string) string {
+	if o.APIKeyEnv != "" {
+		return os.Getenv(o.APIKeyEnv)
+	}
+	for _, env := range defaultEnvs {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// New creates the provider implementation for the given provider name
+func New(name models.Provider, opts Options) (Provider, error) {
+	switch name {
+	case models.ProviderOpenAI:
+		return NewOpenAI(opts), nil
+	case models.ProviderAnthropic:
+		return NewAnthropic(opts), nil
+	case models.ProviderGoogle:
+		return NewGoogle(opts), nil
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", name)
+	}
+}
@@ -0,0 +1,38 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package provider
+
+import (
+	"testing"
+
+	"github.com/koooyooo/mdai/models"
+)
+
+func TestNew(t *testing.T) {
+	for _, name := range []models.Provider{models.ProviderOpenAI, models.ProviderAnthropic, models.ProviderGoogle} {
+		p, err := New(name, Options{})
+		if err != nil {
+			t.Fatalf("New(%s) failed: %v", name, err)
+		}
+		if p.Name() != name {
+			t.Errorf("New(%s).Name() = %s", name, p.Name())
+		}
+	}
+	if _, err := New("unknown", Options{}); err == nil {
+		t.Error("New() with an unknown provider succeeded")
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	t.Setenv("FIRST_KEY", "")
+	t.Setenv("SECOND_KEY", "second")
+	t.Setenv("MY_KEY", "mine")
+
+	if got := (Options{}).apiKey("FIRST_KEY", "SECOND_KEY"); got != "second" {
+		t.Errorf("apiKey() = %q, want the first non-empty default", got)
+	}
+	if got := (Options{APIKeyEnv: "MY_KEY"}).apiKey("SECOND_KEY"); got != "mine" {
+		t.Errorf("apiKey() = %q, want the configured variable", got)
+	}
+}
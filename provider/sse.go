@@ -0,0 +1,63 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package provider
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/koooyooo/mdai/models"
+)
+
+// sseEvent represents a single server-sent event
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// readSSE reads server-sent events from r and calls eventFunc for each complete event.
+// A connection dropped mid-stream is reported as a network error of the provider.
+func readSSE(provider models.Provider, r io.Reader, eventFunc func(ev sseEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var ev sseEvent
+	var data []string
+	flush := func() error {
+		if len(data) == 0 && ev.Event == "" {
+			return nil
+		}
+		ev.Data = strings.Join(data, "\n")
+		err := eventFunc(ev)
+		ev = sseEvent{}
+		data = nil
+		return err
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// comment line
+		case strings.HasPrefix(line, "event:"):
+			ev.Event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("failed to read %s stream: %v", provider, err)
+		}
+		return newNetworkError(provider, err)
+	}
+	return flush()
+}
@@ -0,0 +1,68 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package provider
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/koooyooo/mdai/models"
+)
+
+func TestReadSSE(t *testing.T) {
+	stream := ": keep-alive\n" +
+		"event: message_start\r\n" +
+		"data: {\"a\":1}\r\n" +
+		"\r\n" +
+		"data: line1\n" +
+		"data:line2\n" +
+		"\n" +
+		"data: last"
+
+	var got []sseEvent
+	err := readSSE(models.ProviderAnthropic, strings.NewReader(stream), func(ev sseEvent) error {
+		got = append(got, ev)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []sseEvent{
+		{Event: "message_start", Data: `{"a":1}`},
+		{Data: "line1\nline2"},
+		{Data: "last"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadSSEErrors(t *testing.T) {
+	stop := errors.New("stop")
+	err := readSSE(models.ProviderGoogle, strings.NewReader("data: 1\n\ndata: 2\n\n"), func(ev sseEvent) error {
+		return stop
+	})
+	if err != stop {
+		t.Errorf("error of the event function = %v, want it as is", err)
+	}
+
+	// A connection dropped mid-stream is a retryable network error
+	dropped := io.MultiReader(strings.NewReader("data: 1\n\n"), &failingReader{err: io.ErrUnexpectedEOF})
+	err = readSSE(models.ProviderGoogle, dropped, func(ev sseEvent) error { return nil })
+	apiErr, ok := AsError(err)
+	if !ok || apiErr.Kind != ErrorKindNetwork || apiErr.Provider != models.ProviderGoogle {
+		t.Errorf("error of a dropped stream = %v, want a Google network error", err)
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// parseRanks parses a tiktoken rank file ("<base64 token> <rank>" per line)
+func parseRanks(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int, 200000)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		sep := bytes.IndexByte(line, ' ')
+		if sep < 0 {
+			return nil, fmt.Errorf("invalid rank line %d", lineNo)
+		}
+		token, err := base64.StdEncoding.DecodeString(string(line[:sep]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid token on rank line %d: %v", lineNo, err)
+		}
+		rank, err := strconv.Atoi(string(line[sep+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank on rank line %d: %v", lineNo, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ranks, nil
+}
+
+// bytePairEncode encodes a single pre-tokenized piece by repeatedly merging
+// the adjacent pair with the lowest rank, as tiktoken does
+func bytePairEncode(piece []byte, ranks map[string]int) []int {
+	if rank, ok := ranks[string(piece)]; ok {
+		return []int{rank}
+	}
+
+	// parts[i] is the start offset of the i-th part; the last entry marks the end
+	parts := make([]int, len(piece)+1)
+	for i := range parts {
+		parts[i] = i
+	}
+
+	pairRank := func(i int) int {
+		if i+2 >= len(parts) {
+			return math.MaxInt
+		}
+		if rank, ok := ranks[string(piece[parts[i]:parts[i+2]])]; ok {
+			return rank
+		}
+		return math.MaxInt
+	}
+
+	for len(parts) > 2 {
+		minRank, minIndex := math.MaxInt, -1
+		for i := 0; i < len(parts)-2; i++ {
+			if rank := pairRank(i); rank < minRank {
+				minRank, minIndex = rank, i
+			}
+		}
+		if minIndex < 0 {
+			break
+		}
+		parts = append(parts[:minIndex+1], parts[minIndex+2:]...)
+	}
+
+	tokens := make([]int, 0, len(parts)-1)
+	for i := 0; i < len(parts)-1; i++ {
+		rank, ok := ranks[string(piece[parts[i]:parts[i+1]])]
+		if !ok {
+			// Every single byte has a rank in a complete vocabulary
+			rank = -1
+		}
+		tokens = append(tokens, rank)
+	}
+	return tokens
+}
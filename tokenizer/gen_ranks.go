@@ -0,0 +1,78 @@
+//go:build ignore
+
+/*
+Copyright © 2025 koooyooo
+*/
+
+// gen_ranks downloads the BPE rank files published with tiktoken, verifies their checksums
+// and writes them gzip-compressed to data/, where they are embedded into the binary.
+//
+//	go generate ./tokenizer
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const baseURL = "https://openaipublic.blob.core.windows.net/encodings/"
+
+// expectedHashes are the SHA-256 checksums of the rank files, as verified by tiktoken
+var expectedHashes = map[string]string{
+	"cl100k_base": "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcf6bbb5e8a4",
+	"o200k_base":  "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d",
+}
+
+func main() {
+	for _, name := range []string{"cl100k_base", "o200k_base"} {
+		if err := generate(name); err != nil {
+			fmt.Fprintf(os.Stderr, "fail in generating %s: %v\n", name, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func generate(name string) error {
+	res, err := http.Get(baseURL + name + ".tiktoken")
+	if err != nil {
+		return fmt.Errorf("failed to download rank file: %v", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download rank file: %s", res.Status)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to download rank file: %v", err)
+	}
+
+	sum := sha256.Sum256(data)
+	if hash := hex.EncodeToString(sum[:]); hash != expectedHashes[name] {
+		return fmt.Errorf("checksum mismatch: got %s, want %s", hash, expectedHashes[name])
+	}
+
+	path := filepath.Join("data", name+".tiktoken.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	zw, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s (%d bytes uncompressed)\n", path, len(data))
+	return f.Close()
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package tokenizer
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The pre-tokenization patterns of tiktoken end with whitespace rules using a
+// negative lookahead (\s+(?!\S)), which RE2 does not support. The patterns below
+// hold the remaining alternatives, and the whitespace rules are applied by hand.
+// \s is spelled out because RE2's \s only covers ASCII whitespace.
+const (
+	ws         = `\t\n\v\f\r\x{85}\p{Z}`
+	contractns = `(?i:'s|'t|'re|'ve|'m|'ll|'d)`
+
+	cl100kPattern = `^(?:` + contractns +
+		`|[^\r\n\p{L}\p{N}]?\p{L}+` +
+		`|\p{N}{1,3}` +
+		`| ?[^` + ws + `\p{L}\p{N}]+[\r\n]*)`
+
+	o200kPattern = `^(?:` +
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+` + contractns + `?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*` + contractns + `?` +
+		`|\p{N}{1,3}` +
+		`| ?[^` + ws + `\p{L}\p{N}]+[\r\n/]*)`
+)
+
+var (
+	cl100kRegexp = regexp.MustCompile(cl100kPattern)
+	o200kRegexp  = regexp.MustCompile(o200kPattern)
+)
+
+// pretokenize splits text into the pieces that are encoded independently
+func pretokenize(text string, re *regexp.Regexp) []string {
+	var pieces []string
+	for i := 0; i < len(text); {
+		n := 0
+		if loc := re.FindStringIndex(text[i:]); loc != nil && loc[1] > 0 {
+			n = loc[1]
+		} else {
+			n = matchWhitespace(text[i:])
+		}
+		if n == 0 {
+			// Unreachable for valid patterns; consume one rune to guarantee progress
+			_, n = utf8.DecodeRuneInString(text[i:])
+		}
+		pieces = append(pieces, text[i:i+n])
+		i += n
+	}
+	return pieces
+}
+
+// matchWhitespace applies the whitespace alternatives in order:
+// \s*[\r\n]+, \s+(?!\S) and \s+
+func matchWhitespace(s string) int {
+	end, lastNewline, runes := 0, -1, 0
+	for end < len(s) {
+		r, size := utf8.DecodeRuneInString(s[end:])
+		if !isSpace(r) {
+			break
+		}
+		if r == '\r' || r == '\n' {
+			lastNewline = end + size
+		}
+		end += size
+		runes++
+	}
+	if end == 0 {
+		return 0
+	}
+
+	// \s*[\r\n]+ ends right after the last line break of the run
+	if lastNewline > 0 {
+		return lastNewline
+	}
+	// \s+(?!\S) leaves the last whitespace to prefix the following word
+	if end == len(s) {
+		return end
+	}
+	if runes > 1 {
+		_, size := utf8.DecodeLastRuneInString(s[:end])
+		return end - size
+	}
+	// \s+
+	return end
+}
+
+func isSpace(r rune) bool {
+	switch r {
+	case '\t', '\n', '\v', '\f', '\r', 0x85:
+		return true
+	}
+	return unicode.In(r, unicode.Z)
+}
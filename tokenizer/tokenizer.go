@@ -0,0 +1,191 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package tokenizer
+
+import (
+	"compress/gzip"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Names of the supported encodings
+const (
+	Cl100kBase = "cl100k_base"
+	O200kBase  = "o200k_base"
+)
+
+// ErrRanksNotFound is returned when the rank file of an encoding is neither embedded nor installed
+var ErrRanksNotFound = errors.New("tokenizer rank file not found")
+
+//go:generate go run gen_ranks.go
+
+//go:embed data
+var dataFS embed.FS
+
+// Encoding is a byte pair encoding with its pre-tokenization pattern
+type Encoding struct {
+	name    string
+	pattern *regexp.Regexp
+	ranks   map[string]int
+}
+
+// Name returns the name of the encoding
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Encode converts text into token ranks, treating special tokens as plain text
+func (e *Encoding) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range pretokenize(text, e.pattern) {
+		tokens = append(tokens, bytePairEncode([]byte(piece), e.ranks)...)
+	}
+	return tokens
+}
+
+// Count returns the number of tokens in text
+func (e *Encoding) Count(text string) int {
+	count := 0
+	for _, piece := range pretokenize(text, e.pattern) {
+		if _, ok := e.ranks[piece]; ok {
+			count++
+			continue
+		}
+		count += len(bytePairEncode([]byte(piece), e.ranks))
+	}
+	return count
+}
+
+var (
+	encodings   = map[string]*Encoding{}
+	encodingsMu sync.Mutex
+)
+
+// GetEncoding returns the named encoding, loading its rank file on first use.
+// Rank files are looked up in the embedded data and then in ~/.mdai/tokenizer.
+func GetEncoding(name string) (*Encoding, error) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+
+	if enc, ok := encodings[name]; ok {
+		return enc, nil
+	}
+
+	var pattern *regexp.Regexp
+	switch name {
+	case Cl100kBase:
+		pattern = cl100kRegexp
+	case O200kBase:
+		pattern = o200kRegexp
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", name)
+	}
+
+	r, err := openRanks(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	ranks, err := parseRanks(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s rank file: %v", name, err)
+	}
+
+	enc := &Encoding{name: name, pattern: pattern, ranks: ranks}
+	encodings[name] = enc
+	return enc, nil
+}
+
+// openRanks opens the rank file of the encoding: embedded gzip-compressed or plain, or installed
+func openRanks(name string) (io.ReadCloser, error) {
+	fileName := name + ".tiktoken"
+	if f, err := dataFS.Open("data/" + fileName + ".gz"); err == nil {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to open embedded %s: %v", fileName, err)
+		}
+		return zr, nil
+	}
+	if f, err := dataFS.Open("data/" + fileName); err == nil {
+		return f, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRanksNotFound, fileName)
+	}
+	f, err := os.Open(filepath.Join(homeDir, ".mdai", "tokenizer", fileName))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrRanksNotFound, fileName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// EncodingForModel returns the encoding used by the model.
+// Models of other providers use cl100k_base as an approximation.
+func EncodingForModel(modelID string) string {
+	switch {
+	case strings.HasPrefix(modelID, "gpt-4o"),
+		strings.HasPrefix(modelID, "gpt-4.1"),
+		strings.HasPrefix(modelID, "o1"),
+		strings.HasPrefix(modelID, "o3"),
+		strings.HasPrefix(modelID, "o4"):
+		return O200kBase
+	default:
+		return Cl100kBase
+	}
+}
+
+// Count returns the number of tokens in text for the model.
+// exact is false when the count is approximated, either because the model
+// is not an OpenAI model or because the rank file is not available.
+func Count(modelID, text string) (count int, exact bool) {
+	enc, err := GetEncoding(EncodingForModel(modelID))
+	if err != nil {
+		return Approximate(text), false
+	}
+	return enc.Count(text), isOpenAIModel(modelID)
+}
+
+func isOpenAIModel(modelID string) bool {
+	for _, prefix := range []string{"gpt-", "o1", "o3", "o4"} {
+		if strings.HasPrefix(modelID, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Approximate estimates the token count of text without a vocabulary:
+// about 4 ASCII characters per token, and one token per non-ASCII character
+func Approximate(text string) int {
+	var ascii, other int
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// Chat formatting overhead of the OpenAI chat completion format
+const (
+	TokensPerMessage = 3
+	TokensPerReply   = 3
+)
@@ -0,0 +1,176 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package tokenizer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRanks builds a rank file from tokens ranked in the given order
+func testRanks(tokens ...string) string {
+	var b strings.Builder
+	for rank, token := range tokens {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+	return b.String()
+}
+
+func TestParseRanks(t *testing.T) {
+	ranks, err := parseRanks(strings.NewReader(testRanks("a", "b", " ab") + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"a": 0, "b": 1, " ab": 2}
+	if !reflect.DeepEqual(ranks, want) {
+		t.Errorf("parseRanks() = %v, want %v", ranks, want)
+	}
+
+	for _, invalid := range []string{"YQ==\n", "!!! 1\n", "YQ== x\n"} {
+		if _, err := parseRanks(strings.NewReader(invalid)); err == nil {
+			t.Errorf("parseRanks(%q) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestBytePairEncode(t *testing.T) {
+	ranks, err := parseRanks(strings.NewReader(testRanks("a", "b", "c", "ab", "bc", "abc", "cb")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		piece string
+		want  []int
+	}{
+		{"abc", []int{5}},
+		// ab (3) is merged before bc (4), and abc (5) before cb (6)
+		{"abcb", []int{5, 1}},
+		{"bcb", []int{4, 1}},
+		// bc (4) is merged before cb (6)
+		{"cbc", []int{2, 4}},
+		{"a", []int{0}},
+		// Bytes missing from an incomplete vocabulary are reported as -1
+		{"ax", []int{0, -1}},
+	}
+	for _, tt := range tests {
+		if got := bytePairEncode([]byte(tt.piece), ranks); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("bytePairEncode(%q) = %v, want %v", tt.piece, got, tt.want)
+		}
+	}
+}
+
+func TestPretokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		re   string
+		text string
+		want []string
+	}{
+		{Cl100kBase, cl100kPattern, "Hello world", []string{"Hello", " world"}},
+		{Cl100kBase, cl100kPattern, "I'm 12345", []string{"I", "'m", " ", "123", "45"}},
+		{Cl100kBase, cl100kPattern, "foo  bar", []string{"foo", " ", " bar"}},
+		{Cl100kBase, cl100kPattern, "a \n\nb", []string{"a", " \n\n", "b"}},
+		{Cl100kBase, cl100kPattern, "end  ", []string{"end", "  "}},
+		{Cl100kBase, cl100kPattern, "x!!\n", []string{"x", "!!\n"}},
+		{Cl100kBase, cl100kPattern, "日本語 テキスト", []string{"日本語", " テキスト"}},
+		{Cl100kBase, cl100kPattern, "a　b", []string{"a", "　b"}},
+		{O200kBase, o200kPattern, "HelloWorld", []string{"Hello", "World"}},
+		{O200kBase, o200kPattern, "path/to", []string{"path", "/to"}},
+		{O200kBase, o200kPattern, "DON'T", []string{"DON'T"}},
+	}
+	for _, tt := range tests {
+		re := cl100kRegexp
+		if tt.re == o200kPattern {
+			re = o200kRegexp
+		}
+		got := pretokenize(tt.text, re)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: pretokenize(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+		if strings.Join(got, "") != tt.text {
+			t.Errorf("%s: pieces of %q do not reproduce the text", tt.name, tt.text)
+		}
+	}
+}
+
+func TestEncodingCount(t *testing.T) {
+	ranks, err := parseRanks(strings.NewReader(testRanks("H", "e", "l", "o", " ", "w", "r", "d", "He", "ll", "Hell", "Hello", " w", "or", " wor")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := &Encoding{name: "test", pattern: cl100kRegexp, ranks: ranks}
+	// "Hello" is a single token; " world" merges into " wor", "l", "d"
+	if got := enc.Encode("Hello world"); !reflect.DeepEqual(got, []int{11, 14, 2, 7}) {
+		t.Errorf("Encode() = %v", got)
+	}
+	if got := enc.Count("Hello world"); got != 4 {
+		t.Errorf("Count() = %d, want 4", got)
+	}
+}
+
+func TestEncodingForModel(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4o-mini":            O200kBase,
+		"gpt-4.1":                O200kBase,
+		"o3-mini":                O200kBase,
+		"gpt-4-turbo":            Cl100kBase,
+		"gpt-3.5-turbo":          Cl100kBase,
+		"claude-3-opus-20240229": Cl100kBase,
+	}
+	for model, want := range tests {
+		if got := EncodingForModel(model); got != want {
+			t.Errorf("EncodingForModel(%q) = %s, want %s", model, got, want)
+		}
+	}
+}
+
+func TestApproximate(t *testing.T) {
+	tests := map[string]int{
+		"":      0,
+		"abcd":  1,
+		"abcde": 2,
+		"日本語":   3,
+		"ab日本":  3,
+	}
+	for text, want := range tests {
+		if got := Approximate(text); got != want {
+			t.Errorf("Approximate(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestGetEncodingInstalled(t *testing.T) {
+	if f, err := dataFS.Open("data/" + Cl100kBase + ".tiktoken.gz"); err == nil {
+		_ = f.Close()
+		t.Skip("rank files are embedded")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	if _, err := GetEncoding(O200kBase); !errors.Is(err, ErrRanksNotFound) {
+		t.Fatalf("GetEncoding() without a rank file = %v, want ErrRanksNotFound", err)
+	}
+
+	dir := filepath.Join(home, ".mdai", "tokenizer")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ranks := testRanks("a", "b", "ab")
+	if err := os.WriteFile(filepath.Join(dir, Cl100kBase+".tiktoken"), []byte(ranks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := GetEncoding(Cl100kBase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := enc.Encode("abab"); !reflect.DeepEqual(got, []int{2, 2}) {
+		t.Errorf("Encode() = %v, want [2 2]", got)
+	}
+}
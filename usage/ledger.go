@@ -0,0 +1,120 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Record represents a single API call in the usage ledger
+type Record struct {
+	Timestamp        time.Time `json:"timestamp"`
+	Operation        string    `json:"operation"`
+	File             string    `json:"file"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`
+	// Currency is empty when the cost of the model is unknown
+	Currency string `json:"currency"`
+}
+
+// Ledger is an append-only JSON Lines file of usage records
+type Ledger struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewLedger creates a ledger stored at the given path
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// DefaultPath returns the default ledger path (~/.mdai/usage.jsonl)
+func DefaultPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, ".mdai", "usage.jsonl"), nil
+}
+
+// Path returns the path of the ledger file
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Append adds a record to the end of the ledger
+func (l *Ledger) Append(rec Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create ledger directory: %v", err)
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage record: %v", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage record: %v", err)
+	}
+	return nil
+}
+
+// Load reads all records from the ledger. A missing ledger has no records.
+func (l *Ledger) Load() ([]Record, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("failed to parse ledger line %d: %v", lineNo, err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %v", err)
+	}
+	return records, nil
+}
+
+// SumCost returns the total known cost of the records at or after since
+func SumCost(records []Record, since time.Time) float64 {
+	var total float64
+	for _, rec := range records {
+		if rec.Timestamp.Before(since) {
+			continue
+		}
+		total += rec.Cost
+	}
+	return total
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// GroupBy represents the dimension used to aggregate records
+type GroupBy string
+
+const (
+	GroupByDay       GroupBy = "day"
+	GroupByModel     GroupBy = "model"
+	GroupByOperation GroupBy = "operation"
+	GroupByFile      GroupBy = "file"
+)
+
+// ParseGroupBy converts a string into a GroupBy
+func ParseGroupBy(s string) (GroupBy, error) {
+	switch g := GroupBy(s); g {
+	case GroupByDay, GroupByModel, GroupByOperation, GroupByFile:
+		return g, nil
+	}
+	return "", fmt.Errorf("unsupported grouping: %s (day, model, operation, file)", s)
+}
+
+// Summary represents aggregated usage for one group
+type Summary struct {
+	Key              string  `json:"key"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	Currency         string  `json:"currency"`
+	// UnknownCostCalls counts calls whose cost could not be calculated
+	UnknownCostCalls int `json:"unknown_cost_calls"`
+}
+
+// Aggregate groups records by the given dimension, sorted by key
+func Aggregate(records []Record, by GroupBy) []Summary {
+	index := map[string]*Summary{}
+	var keys []string
+	for _, rec := range records {
+		key := groupKey(rec, by)
+		s, ok := index[key]
+		if !ok {
+			s = &Summary{Key: key}
+			index[key] = s
+			keys = append(keys, key)
+		}
+		s.Calls++
+		s.PromptTokens += rec.PromptTokens
+		s.CompletionTokens += rec.CompletionTokens
+		if rec.Currency == "" {
+			s.UnknownCostCalls++
+			continue
+		}
+		s.Cost += rec.Cost
+		switch s.Currency {
+		case "", rec.Currency:
+			s.Currency = rec.Currency
+		default:
+			s.Currency = "mixed"
+		}
+	}
+
+	sort.Strings(keys)
+	summaries := make([]Summary, 0, len(keys))
+	for _, key := range keys {
+		summaries = append(summaries, *index[key])
+	}
+	return summaries
+}
+
+func groupKey(rec Record, by GroupBy) string {
+	switch by {
+	case GroupByModel:
+		return rec.Model
+	case GroupByOperation:
+		return rec.Operation
+	case GroupByFile:
+		return rec.File
+	default:
+		return rec.Timestamp.Local().Format("2006-01-02")
+	}
+}
+
+// WriteTable writes the summaries as an aligned text table
+func WriteTable(w io.Writer, by GroupBy, summaries []Summary) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tCALLS\tPROMPT\tCOMPLETION\tCOST\tCURRENCY\tUNKNOWN COST\n", strings.ToUpper(string(by)))
+
+	var total Summary
+	for _, s := range summaries {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.5f\t%s\t%d\n", s.Key, s.Calls, s.PromptTokens, s.CompletionTokens, s.Cost, s.Currency, s.UnknownCostCalls)
+		total.Calls += s.Calls
+		total.PromptTokens += s.PromptTokens
+		total.CompletionTokens += s.CompletionTokens
+		total.Cost += s.Cost
+		total.UnknownCostCalls += s.UnknownCostCalls
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\t%d\t%d\t%.5f\t\t%d\n", total.Calls, total.PromptTokens, total.CompletionTokens, total.Cost, total.UnknownCostCalls)
+	return tw.Flush()
+}
+
+// WriteCSV writes the summaries as CSV with a header row
+func WriteCSV(w io.Writer, by GroupBy, summaries []Summary) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{string(by), "calls", "prompt_tokens", "completion_tokens", "cost", "currency", "unknown_cost_calls"}); err != nil {
+		return err
+	}
+	for _, s := range summaries {
+		if err := cw.Write([]string{
+			s.Key,
+			strconv.Itoa(s.Calls),
+			strconv.Itoa(s.PromptTokens),
+			strconv.Itoa(s.CompletionTokens),
+			strconv.FormatFloat(s.Cost, 'f', 5, 64),
+			s.Currency,
+			strconv.Itoa(s.UnknownCostCalls),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the summaries as an indented JSON array
+func WriteJSON(w io.Writer, summaries []Summary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(summaries)
+}
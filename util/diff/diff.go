@@ -0,0 +1,232 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change
+const contextLines = 3
+
+// op is a line of the edit script: ' ' (kept), '-' (deleted) or '+' (inserted).
+// from and to are the numbers of lines of each side consumed before the line.
+type op struct {
+	kind     byte
+	line     string
+	from, to int
+}
+
+// Unified returns the unified diff of two texts, or an empty string when they are equal
+func Unified(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	ops := lineDiff(splitLines(from), splitLines(to))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks(ops) {
+		writeHunk(&b, h)
+	}
+	return b.String()
+}
+
+// splitLines splits the text after each newline; the last line may have none
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxEditDistance bounds the search of the shortest edit script, whose memory grows with its square.
+// Texts that differ more are shown as their changed middle deleted and inserted as a whole.
+const maxEditDistance = 2000
+
+// lineDiff computes the edit script of the lines, without the common prefix and suffix
+// in the Myers search so that small edits of large documents stay cheap
+func lineDiff(a, b []string) []op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []op
+	for _, line := range a[:prefix] {
+		ops = append(ops, op{kind: ' ', line: line})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, op{kind: ' ', line: line})
+	}
+
+	from, to := 0, 0
+	for i := range ops {
+		ops[i].from, ops[i].to = from, to
+		if ops[i].kind != '+' {
+			from++
+		}
+		if ops[i].kind != '-' {
+			to++
+		}
+	}
+	return ops
+}
+
+// myers computes the shortest edit script with the Myers algorithm.
+// trace[d] keeps the furthest x of the diagonals -d-1..d+1 before step d.
+func myers(a, b []string) []op {
+	n, m := len(a), len(b)
+	limit := n + m
+	if limit > maxEditDistance {
+		limit = maxEditDistance
+	}
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	found := false
+search:
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset]
+			} else {
+				x = v[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				found = true
+				break search
+			}
+		}
+	}
+	if !found {
+		return replaceAll(a, b)
+	}
+
+	// Walk back from the end, collecting the script in reverse
+	var ops []op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+d+1] < v[k+1+d+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+d+1]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{kind: ' ', line: a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, op{kind: '+', line: b[prevY]})
+			} else {
+				ops = append(ops, op{kind: '-', line: a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+func replaceAll(a, b []string) []op {
+	ops := make([]op, 0, len(a)+len(b))
+	for _, line := range a {
+		ops = append(ops, op{kind: '-', line: line})
+	}
+	for _, line := range b {
+		ops = append(ops, op{kind: '+', line: line})
+	}
+	return ops
+}
+
+// hunks groups the changes with their context; changes closer than twice the context share a hunk
+func hunks(ops []op) [][]op {
+	var result [][]op
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := max(i-contextLines, 0)
+		j := i
+		for {
+			for j < len(ops) && ops[j].kind != ' ' {
+				j++
+			}
+			k := j
+			for k < len(ops) && ops[k].kind == ' ' {
+				k++
+			}
+			if k < len(ops) && k-j <= 2*contextLines {
+				j = k
+				continue
+			}
+			break
+		}
+		end := min(j+contextLines, len(ops))
+		result = append(result, ops[start:end])
+		i = end
+	}
+	return result
+}
+
+func writeHunk(b *strings.Builder, h []op) {
+	fromCount, toCount := 0, 0
+	for _, o := range h {
+		if o.kind != '+' {
+			fromCount++
+		}
+		if o.kind != '-' {
+			toCount++
+		}
+	}
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(h[0].from, fromCount), hunkRange(h[0].to, toCount))
+	for _, o := range h {
+		b.WriteByte(o.kind)
+		b.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the range of a hunk; an empty range starts at the line before it
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,132 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// apply rebuilds both sides from the edit script
+func apply(ops []op) (string, string) {
+	var from, to strings.Builder
+	for _, o := range ops {
+		if o.kind != '+' {
+			from.WriteString(o.line)
+		}
+		if o.kind != '-' {
+			to.WriteString(o.line)
+		}
+	}
+	return from.String(), to.String()
+}
+
+func countChanges(ops []op) int {
+	n := 0
+	for _, o := range ops {
+		if o.kind != ' ' {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		changes int
+	}{
+		{"equal", "a\nb\nc\n", "a\nb\nc\n", 0},
+		{"insert", "a\nc\n", "a\nb\nc\n", 1},
+		{"delete", "a\nb\nc\n", "a\nc\n", 1},
+		{"replace", "a\nb\nc\n", "a\nx\nc\n", 2},
+		{"from empty", "", "a\nb\n", 2},
+		{"to empty", "a\nb\n", "", 2},
+		{"move", "a\nb\nc\nd\n", "b\nc\nd\na\n", 2},
+		{"classic", "a\nb\nc\na\nb\nb\na\n", "c\nb\na\nb\na\nc\n", 5},
+		{"no trailing newline", "a\nb", "a\nb\n", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := lineDiff(splitLines(tt.from), splitLines(tt.to))
+			from, to := apply(ops)
+			if from != tt.from || to != tt.to {
+				t.Fatalf("script rebuilds %q -> %q, want %q -> %q", from, to, tt.from, tt.to)
+			}
+			if got := countChanges(ops); got != tt.changes {
+				t.Errorf("changes = %d, want %d", got, tt.changes)
+			}
+		})
+	}
+}
+
+func TestMyersBeyondMaxEditDistance(t *testing.T) {
+	var a, b []string
+	for i := 0; i < maxEditDistance; i++ {
+		a = append(a, "a\n")
+		b = append(b, "b\n")
+	}
+	ops := myers(a, b)
+	if got := countChanges(ops); got != 2*maxEditDistance {
+		t.Fatalf("changes = %d, want %d", got, 2*maxEditDistance)
+	}
+	if ops[0].kind != '-' || ops[len(ops)-1].kind != '+' {
+		t.Errorf("expected the whole text to be replaced")
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{
+			name: "equal",
+			from: "a\n",
+			to:   "a\n",
+			want: "",
+		},
+		{
+			name: "single change",
+			from: "a\nb\nc\n",
+			to:   "a\nx\nc\n",
+			want: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			name: "insert into empty",
+			from: "",
+			to:   "a\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name: "missing newline",
+			from: "a",
+			to:   "a\n",
+			want: "--- old\n+++ new\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n",
+		},
+		{
+			name: "separate hunks",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			to:   "x\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ny\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+y\n",
+		},
+		{
+			name: "close changes share a hunk",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n",
+			to:   "x\n2\n3\n4\n5\n6\n7\ny\n",
+			want: "--- old\n+++ new\n@@ -1,8 +1,8 @@\n-1\n+x\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+y\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("old", "new", tt.from, tt.to); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package file
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/koooyooo/mdai/markdown"
+)
+
+var (
+	answerOpenRegexp  = regexp.MustCompile(`^<!--\s*mdai:answer(?:\s[^>]*)?\s*-->$`)
+	answerCloseRegexp = regexp.MustCompile(`^<!--\s*/mdai:answer(?:\s[^>]*)?\s*-->$`)
+	markerAttrRegexp  = regexp.MustCompile(`([A-Za-z_]+)="([^"]*)"`)
+)
+
+// InterruptedNote is written after a partial answer whose generation was interrupted
+const InterruptedNote = "*[answer interrupted]*"
+
+// AnswerOpenMarker returns the marker written before an answer, carrying the model and the time
+func AnswerOpenMarker(model string, t time.Time) string {
+	return fmt.Sprintf(`<!-- mdai:answer model="%s" time="%s" -->`, model, t.Format(time.RFC3339))
+}
+
+// AnswerCloseMarker returns the marker written after an answer, carrying the cost when it is known
+func AnswerCloseMarker(cost string) string {
+	if cost == "" {
+		return "<!-- /mdai:answer -->"
+	}
+	return fmt.Sprintf(`<!-- /mdai:answer cost="%s" -->`, cost)
+}
+
+// Answer is a region between the answer markers
+type Answer struct {
+	// Text is the content between the markers
+	Text string
+	// Model, Time and Cost are the attributes of the markers; empty when missing
+	Model string
+	Time  string
+	Cost  string
+	// Offset and End are byte offsets of the whole region, including the markers
+	Offset int
+	End    int
+}
+
+// Question is a block quote outside of the answers
+type Question struct {
+	// Text is the question without the quote markers
+	Text string
+	// Offset and End are byte offsets of the block quote
+	Offset int
+	End    int
+	// Answer is the first answer after the question and before the next one, or nil
+	Answer *Answer
+}
+
+// Answered reports whether an answer follows the question
+func (q Question) Answered() bool {
+	return q.Answer != nil
+}
+
+// Questions returns the non-empty block quotes in document order, paired with their answers.
+// Block quotes inside answers are not questions.
+func Questions(content string) []Question {
+	var questions []Question
+	for _, item := range parseQA(content) {
+		switch {
+		case item.quote != nil:
+			questions = append(questions, Question{
+				Text:   strings.TrimSpace(item.quote.Text),
+				Offset: item.quote.Offset,
+				End:    item.quote.End,
+			})
+		case len(questions) > 0 && questions[len(questions)-1].Answer == nil:
+			questions[len(questions)-1].Answer = item.answer
+		}
+	}
+	return questions
+}
+
+// Answers returns the answer regions in document order
+func Answers(content string) []*Answer {
+	var answers []*Answer
+	for _, item := range parseQA(content) {
+		if item.answer != nil {
+			answers = append(answers, item.answer)
+		}
+	}
+	return answers
+}
+
+// qaItem is either a question block quote or an answer
+type qaItem struct {
+	quote  *markdown.Block
+	answer *Answer
+}
+
+// parseQA returns the question block quotes and the answers in document order.
+// The answer markers are matched line by line, so that an answer ending in raw HTML or in an unclosed
+// code block cannot hide its closing marker: an open marker counts outside code blocks,
+// and the first closing marker line after it ends the answer, since mdai always writes it outside code blocks.
+// An answer without a closing marker extends to the end of the content.
+func parseQA(content string) []qaItem {
+	var items []qaItem
+	lines := rawLines(content)
+	prev := 0
+	var fence markdown.Fence
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if fence.Next(l.text) || !answerOpenRegexp.MatchString(markerLine(l.text)) {
+			continue
+		}
+		items = append(items, quoteItems(content, prev, l.offset)...)
+		attrs := markerAttrs(l.text)
+		a := &Answer{
+			Text:   content[l.end:],
+			Model:  attrs["model"],
+			Time:   attrs["time"],
+			Offset: l.offset,
+			End:    len(content),
+		}
+		for j := i + 1; j < len(lines); j++ {
+			if answerCloseRegexp.MatchString(markerLine(lines[j].text)) {
+				a.Text = content[l.end:lines[j].offset]
+				a.End = lines[j].end
+				a.Cost = markerAttrs(lines[j].text)["cost"]
+				i = j
+				break
+			}
+		}
+		items = append(items, qaItem{answer: a})
+		prev = a.End
+		if a.End == len(content) {
+			break
+		}
+		fence = markdown.Fence{}
+	}
+	return append(items, quoteItems(content, prev, len(content))...)
+}
+
+// quoteItems returns the non-empty block quotes between start and end, with offsets in the content
+func quoteItems(content string, start, end int) []qaItem {
+	var items []qaItem
+	for _, b := range markdown.Parse(content[start:end]).BlockQuotes() {
+		if strings.TrimSpace(b.Text) == "" {
+			continue
+		}
+		quote := *b
+		quote.Offset += start
+		quote.End += start
+		items = append(items, qaItem{quote: &quote})
+	}
+	return items
+}
+
+// rawLine is a line of the content; end includes the line ending
+type rawLine struct {
+	text   string
+	offset int
+	end    int
+}
+
+func rawLines(content string) []rawLine {
+	var lines []rawLine
+	for offset := 0; offset < len(content); {
+		end := strings.IndexByte(content[offset:], '\n')
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += offset + 1
+		}
+		lines = append(lines, rawLine{text: strings.TrimRight(content[offset:end], "\r\n"), offset: offset, end: end})
+		offset = end
+	}
+	return lines
+}
+
+// markerLine returns the line without surrounding spaces, or an empty string for an indented code line
+func markerLine(text string) string {
+	if strings.HasPrefix(text, "    ") || strings.HasPrefix(text, "\t") {
+		return ""
+	}
+	return strings.TrimSpace(text)
+}
+
+// markedBlock is a range of the content between an open and a close marker line
+type markedBlock struct {
+	open rawLine
+	// close is nil when the block is not closed before the next open marker or the end of the content
+	close *rawLine
+	// line is the 1-based line number of the open marker
+	line int
+}
+
+// markedBlocks finds the blocks between marker lines. An open marker counts outside fenced code,
+// and the block ends at the first close marker after it, even if the generated text leaves a fence
+// or an HTML block open.
+func markedBlocks(content string, isOpen, isClose func(marker string) bool) []markedBlock {
+	lines := rawLines(content)
+	var blocks []markedBlock
+	var fence markdown.Fence
+	for i := 0; i < len(lines); i++ {
+		if fence.Next(lines[i].text) || !isOpen(markerLine(lines[i].text)) {
+			continue
+		}
+		b := markedBlock{open: lines[i], line: i + 1}
+		for j := i + 1; j < len(lines); j++ {
+			marker := markerLine(lines[j].text)
+			if isOpen(marker) {
+				break
+			}
+			if isClose(marker) {
+				b.close = &lines[j]
+				i = j
+				break
+			}
+		}
+		blocks = append(blocks, b)
+		fence = markdown.Fence{}
+	}
+	return blocks
+}
+
+func markerAttrs(marker string) map[string]string {
+	attrs := map[string]string{}
+	for _, m := range markerAttrRegexp.FindAllStringSubmatch(marker, -1) {
+		attrs[m[1]] = m[2]
+	}
+	return attrs
+}
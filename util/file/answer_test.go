@@ -0,0 +1,110 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package file
+
+import (
+	"reflect"
+	"testing"
+)
+
+// qa summarises a question as its text and the text of its answer
+type qa struct {
+	Question string
+	Answer   string
+	Answered bool
+}
+
+func summarizeQuestions(questions []Question) []qa {
+	var s []qa
+	for _, q := range questions {
+		item := qa{Question: q.Text, Answered: q.Answered()}
+		if q.Answered() {
+			item.Answer = q.Answer.Text
+		}
+		s = append(s, item)
+	}
+	return s
+}
+
+func TestQuestions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []qa
+	}{
+		{
+			name:    "unanswered",
+			content: "# T\n\n> q1\n",
+			want:    []qa{{Question: "q1"}},
+		},
+		{
+			name:    "answered",
+			content: "> q1\n\n<!-- mdai:answer model=\"m\" -->\na1\n<!-- /mdai:answer -->\n\n> q2\n",
+			want:    []qa{{Question: "q1", Answer: "a1\n", Answered: true}, {Question: "q2"}},
+		},
+		{
+			name:    "quotes inside answers are not questions",
+			content: "> q1\n<!-- mdai:answer -->\n> quoted in the answer\n<!-- /mdai:answer -->\n",
+			want:    []qa{{Question: "q1", Answer: "> quoted in the answer\n", Answered: true}},
+		},
+		{
+			name:    "answer ending in an unclosed code block",
+			content: "> q1\n<!-- mdai:answer -->\n```go\ncode\n<!-- /mdai:answer -->\n> q2\n",
+			want:    []qa{{Question: "q1", Answer: "```go\ncode\n", Answered: true}, {Question: "q2"}},
+		},
+		{
+			name:    "answer ending in raw html",
+			content: "> q1\n<!-- mdai:answer -->\n<details>\ntext\n<!-- /mdai:answer -->\n> q2\n",
+			want:    []qa{{Question: "q1", Answer: "<details>\ntext\n", Answered: true}, {Question: "q2"}},
+		},
+		{
+			name:    "markers in code blocks are text",
+			content: "> q1\n\n```\n<!-- mdai:answer -->\n```\n\n> q2\n",
+			want:    []qa{{Question: "q1"}, {Question: "q2"}},
+		},
+		{
+			name:    "unclosed answer runs to the end",
+			content: "> q1\n<!-- mdai:answer -->\npartial\n> not a question\n",
+			want:    []qa{{Question: "q1", Answer: "partial\n> not a question\n", Answered: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeQuestions(Questions(tt.content)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Questions() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnswerAttributes(t *testing.T) {
+	content := "> q\n<!-- mdai:answer model=\"gpt-4o\" time=\"2025-01-02T03:04:05Z\" -->\na\n<!-- /mdai:answer cost=\"$0.00100\" -->\n"
+	answers := Answers(content)
+	if len(answers) != 1 {
+		t.Fatalf("answers = %d, want 1", len(answers))
+	}
+	a := answers[0]
+	if a.Model != "gpt-4o" || a.Time != "2025-01-02T03:04:05Z" || a.Cost != "$0.00100" {
+		t.Errorf("attributes = %q %q %q", a.Model, a.Time, a.Cost)
+	}
+	if content[a.Offset:a.End] != content[4:] {
+		t.Errorf("region = %q", content[a.Offset:a.End])
+	}
+}
+
+func TestLastQuestionEnd(t *testing.T) {
+	unanswered := "> q1\n\ntext\n"
+	if got, err := LastQuestionEnd(unanswered); err != nil || got != len("> q1\n") {
+		t.Errorf("LastQuestionEnd(unanswered) = %d, %v", got, err)
+	}
+
+	answered := "> q1\n<!-- mdai:answer -->\na\n<!-- /mdai:answer -->\ntext\n"
+	if got, err := LastQuestionEnd(answered); err != nil || got != len(answered)-len("text\n") {
+		t.Errorf("LastQuestionEnd(answered) = %d, %v", got, err)
+	}
+
+	if _, err := LastQuestionEnd("no question\n"); err == nil {
+		t.Error("LastQuestionEnd() without a question succeeded")
+	}
+}
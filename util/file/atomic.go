@@ -0,0 +1,92 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// AtomicWriter writes to a temporary file in the target's directory,
+// which replaces the target only when Commit is called
+type AtomicWriter struct {
+	f    *os.File
+	path string
+	done bool
+}
+
+// NewAtomicWriter creates a writer for path. The permissions of an existing file are kept.
+func NewAtomicWriter(path string) (*AtomicWriter, error) {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	if err := f.Chmod(mode); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to set permissions: %v", err)
+	}
+	return &AtomicWriter{f: f, path: path}, nil
+}
+
+func (w *AtomicWriter) Write(p []byte) (int, error) {
+	return w.f.Write(p)
+}
+
+func (w *AtomicWriter) WriteString(s string) (int, error) {
+	return w.f.WriteString(s)
+}
+
+// Commit flushes the temporary file and renames it over the target
+func (w *AtomicWriter) Commit() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+
+	if err := w.f.Sync(); err != nil {
+		_ = w.f.Close()
+		_ = os.Remove(w.f.Name())
+		return fmt.Errorf("failed to sync temporary file: %v", err)
+	}
+	if err := w.f.Close(); err != nil {
+		_ = os.Remove(w.f.Name())
+		return fmt.Errorf("failed to close temporary file: %v", err)
+	}
+	if err := os.Rename(w.f.Name(), w.path); err != nil {
+		_ = os.Remove(w.f.Name())
+		return fmt.Errorf("failed to replace file: %v", err)
+	}
+	return nil
+}
+
+// Abort discards the temporary file and leaves the target untouched. It is a no-op after Commit.
+func (w *AtomicWriter) Abort() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+
+	_ = w.f.Close()
+	return os.Remove(w.f.Name())
+}
+
+// WriteFileAtomic replaces the file with data via a temporary file
+func WriteFileAtomic(path string, data []byte) error {
+	w, err := NewAtomicWriter(path)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		_ = w.Abort()
+		return err
+	}
+	return w.Commit()
+}
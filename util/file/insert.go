@@ -0,0 +1,98 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package file
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/koooyooo/mdai/markdown"
+)
+
+var (
+	insertOpenRegexp  = regexp.MustCompile(`^<!--\s*mdai:insert(?:\s[^>]*)?\s*-->$`)
+	insertCloseRegexp = regexp.MustCompile(`^<!--\s*/mdai:insert(?:\s[^>]*)?\s*-->$`)
+	markerRegexp      = regexp.MustCompile(`^<!--\s*mdai:marker(?:\s[^>]*)?\s*-->$`)
+)
+
+// InsertOpenMarker returns the marker written before a block generated by the operation
+func InsertOpenMarker(operation, model string, t time.Time) string {
+	return fmt.Sprintf(`<!-- mdai:insert op="%s" model="%s" time="%s" -->`, operation, model, t.Format(time.RFC3339))
+}
+
+// InsertCloseMarker returns the marker written after a generated block
+func InsertCloseMarker() string {
+	return "<!-- /mdai:insert -->"
+}
+
+// Insertion is a region between the insert markers
+type Insertion struct {
+	// Text is the content between the markers
+	Text string
+	// Operation, Model and Time are the attributes of the open marker; empty when missing
+	Operation string
+	Model     string
+	Time      string
+	// Offset and End are byte offsets of the whole region, including the markers
+	Offset int
+	End    int
+	// Closed is false when the closing marker is missing; the region then covers the open marker only
+	Closed bool
+}
+
+// Insertions returns the generated blocks in document order.
+// A block without a closing marker before the next block or the end of the content is not closed.
+func Insertions(content string) []*Insertion {
+	var insertions []*Insertion
+	for _, b := range markedBlocks(content, insertOpenRegexp.MatchString, insertCloseRegexp.MatchString) {
+		attrs := markerAttrs(b.open.text)
+		ins := &Insertion{
+			Operation: attrs["op"],
+			Model:     attrs["model"],
+			Time:      attrs["time"],
+			Offset:    b.open.offset,
+			End:       b.open.end,
+		}
+		if b.close != nil {
+			ins.Text = content[b.open.end:b.close.offset]
+			ins.End = b.close.end
+			ins.Closed = true
+		}
+		insertions = append(insertions, ins)
+	}
+	return insertions
+}
+
+// FindInsertion returns the first block generated by the operation, or nil
+func FindInsertion(content, operation string) *Insertion {
+	for _, ins := range Insertions(content) {
+		if ins.Operation == operation {
+			return ins
+		}
+	}
+	return nil
+}
+
+// Marker is a <!-- mdai:marker name="..." --> comment placing a generated block
+type Marker struct {
+	Name string
+	// Offset and End are byte offsets of the comment line
+	Offset int
+	End    int
+}
+
+// FindMarker returns the first top-level marker with the name, or nil
+func FindMarker(content, name string) *Marker {
+	for _, b := range markdown.Parse(content).Blocks {
+		if b.Kind != markdown.KindHTML || !markerRegexp.MatchString(strings.TrimSpace(b.Text)) {
+			continue
+		}
+		if markerAttrs(b.Text)["name"] == name {
+			return &Marker{Name: name, Offset: b.Offset, End: b.End}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package file
+
+import "testing"
+
+func TestInsertions(t *testing.T) {
+	const open = `<!-- mdai:insert op="tldr" model="m" time="t" -->`
+	const close = "<!-- /mdai:insert -->"
+	tests := []struct {
+		name    string
+		content string
+		texts   []string
+		closed  []bool
+	}{
+		{"closed block", "# T\n\n" + open + "\nsummary\n\n" + close + "\n\ntext\n", []string{"summary\n\n"}, []bool{true}},
+		{"text ending in html", open + "\n<div>x</div>\n" + close + "\nafter\n", []string{"<div>x</div>\n"}, []bool{true}},
+		{"text leaving a fence open", open + "\n```go\ncode\n" + close + "\n# After\n", []string{"```go\ncode\n"}, []bool{true}},
+		{"missing close marker", open + "\nsummary\n\n# After\n", []string{""}, []bool{false}},
+		{"next block before the close marker", open + "\nfirst\n" + open + "\nsecond\n" + close + "\n", []string{"", "second\n"}, []bool{false, true}},
+		{"marker in fenced code", "```\n" + open + "\n```\n" + close + "\n", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insertions := Insertions(tt.content)
+			if len(insertions) != len(tt.texts) {
+				t.Fatalf("Insertions() = %d blocks, want %d", len(insertions), len(tt.texts))
+			}
+			for i, ins := range insertions {
+				if ins.Text != tt.texts[i] || ins.Closed != tt.closed[i] {
+					t.Errorf("block %d = %q (closed %v), want %q (closed %v)", i, ins.Text, ins.Closed, tt.texts[i], tt.closed[i])
+				}
+				if ins.Operation != "tldr" || tt.content[ins.Offset:ins.Offset+len(open)] != open {
+					t.Errorf("block %d does not start at its open marker: %+v", i, ins)
+				}
+				if !ins.Closed && tt.content[ins.Offset:ins.End] != open+"\n" {
+					t.Errorf("unclosed block %d covers %q, want the open marker only", i, tt.content[ins.Offset:ins.End])
+				}
+			}
+		})
+	}
+}
@@ -5,38 +5,104 @@ package file
 
 import (
 	"fmt"
-	"runtime"
 	"strings"
 )
 
-func getLineSeparator() string {
-	if runtime.GOOS == "windows" {
-		return "\r\n"
+// PriorQuestionLabel marks earlier block quotes kept in the context
+const PriorQuestionLabel = "[Prior question]"
+
+// LoadLastQuote returns the last block quote as the question, and the rest of the content as the context.
+// The question spans every line of the block quote, including lazy continuation lines;
+// nested quotes keep one '>' less. Earlier block quotes stay in the context, marked as prior questions.
+// Lines starting with '>' inside code blocks or answers are not treated as quotes.
+func LoadLastQuote(content string) (string, string, error) {
+	questions := Questions(content)
+	if len(questions) == 0 {
+		return "", "", fmt.Errorf("no quote (line starting with >) found")
 	}
-	return "\n"
+	last := questions[len(questions)-1]
+	return last.Text, questionContext(content, last, 0, len(content)), nil
 }
 
-func LoadLastQuote(content string) (string, string, error) {
-	lineSep := getLineSeparator()
-	lines := strings.Split(content, lineSep)
-	var lastQuote string
-	var otherContents string
+// Turn is an answered question together with the notes written before it
+type Turn struct {
+	Context  string
+	Question string
+	Answer   string
+}
 
-	for _, line := range lines {
-		if !strings.HasPrefix(line, ">") {
-			otherContents += line + lineSep
+// LoadThread returns the answered questions before q as conversation turns,
+// and the context of q: the content from the last answer up to end, without q itself.
+// Without answered questions, the context starts at the beginning of the content.
+func LoadThread(content string, q Question, end int) ([]Turn, string) {
+	var turns []Turn
+	start := 0
+	for _, prior := range Questions(content) {
+		if prior.Offset >= q.Offset {
+			break
+		}
+		if !prior.Answered() {
 			continue
 		}
-		if len(line) > 0 && line[0] == '>' {
-			lastQuote = line[1:] // Part after '>'
-			if len(lastQuote) > 0 && lastQuote[0] == ' ' {
-				lastQuote = lastQuote[1:]
+		turns = append(turns, Turn{
+			Context:  questionContext(content, prior, start, prior.Offset),
+			Question: prior.Text,
+			Answer:   strings.TrimSpace(prior.Answer.Text),
+		})
+		start = prior.Answer.End
+	}
+	return turns, questionContext(content, q, start, end)
+}
+
+// LastQuestionEnd returns the byte offset just after the last block quote, or after its answer when it has one,
+// so that a new answer never comes between a question and its earlier answer
+func LastQuestionEnd(content string) (int, error) {
+	questions := Questions(content)
+	if len(questions) == 0 {
+		return 0, fmt.Errorf("no quote (line starting with >) found")
+	}
+	last := questions[len(questions)-1]
+	if last.Answered() {
+		return last.Answer.End, nil
+	}
+	return last.End, nil
+}
+
+// questionContext returns the content between start and end without the question itself.
+// Other questions are marked as prior questions, and answers lose their markers.
+func questionContext(content string, q Question, start, end int) string {
+	var b strings.Builder
+	prev := start
+	for _, item := range parseQA(content) {
+		if item.quote != nil {
+			quote := item.quote
+			if quote.Offset < start {
+				continue
+			}
+			if quote.Offset >= end {
+				break
 			}
+			b.WriteString(content[prev:quote.Offset])
+			if quote.Offset != q.Offset {
+				b.WriteString(PriorQuestionLabel + "\n")
+				b.WriteString(content[quote.Offset:quote.End])
+			}
+			prev = quote.End
+			continue
 		}
+		a := item.answer
+		if a.Offset < start {
+			continue
+		}
+		if a.Offset >= end {
+			break
+		}
+		b.WriteString(content[prev:a.Offset])
+		b.WriteString(a.Text)
+		prev = a.End
 	}
-
-	if lastQuote == "" {
-		return "", "", fmt.Errorf("no quote (line starting with >) found")
+	if prev < end {
+		b.WriteString(content[prev:end])
 	}
-	return lastQuote, otherContents, nil
+	return b.String()
 }
@@ -0,0 +1,107 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package file
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	regionOpenRegexp  = regexp.MustCompile(`^<!--\s*mdai:([A-Za-z0-9_-]+)((?:\s[^>]*)?)\s*-->$`)
+	regionCloseRegexp = regexp.MustCompile(`^<!--\s*/mdai\s*-->$`)
+	hashAttrRegexp    = regexp.MustCompile(`\shash="[^"]*"`)
+)
+
+// reservedDirectives are the mdai comments that are not directive regions
+var reservedDirectives = map[string]bool{
+	"answer": true,
+	"insert": true,
+	"marker": true,
+}
+
+// RegionCloseMarker is written after the content of a directive region that was not closed
+const RegionCloseMarker = "<!-- /mdai -->"
+
+// Region is a directive region: the content between <!-- mdai:<operation> ... --> and <!-- /mdai -->,
+// generated by running the operation
+type Region struct {
+	Operation string
+	// Attrs are the attributes of the open marker, e.g. section, args and hash
+	Attrs map[string]string
+	// OpenMarker and CloseMarker are the marker lines; CloseMarker is empty when the region is not closed
+	OpenMarker  string
+	CloseMarker string
+	// Text is the content between the markers
+	Text string
+	// Offset and End are byte offsets of the whole region, including the markers
+	Offset int
+	End    int
+	// Line is the 1-based line number of the open marker
+	Line int
+}
+
+// Hash returns the input hash recorded in the open marker, or an empty string
+func (r *Region) Hash() string {
+	return r.Attrs["hash"]
+}
+
+// Regions returns the directive regions in document order.
+// A region without a closing marker before the next region or the end of the content is empty.
+func Regions(content string) []*Region {
+	var regions []*Region
+	for _, b := range markedBlocks(content, isRegionOpen, regionCloseRegexp.MatchString) {
+		marker := markerLine(b.open.text)
+		r := &Region{
+			Operation:  regionOpenRegexp.FindStringSubmatch(marker)[1],
+			Attrs:      markerAttrs(marker),
+			OpenMarker: marker,
+			Offset:     b.open.offset,
+			End:        b.open.end,
+			Line:       b.line,
+		}
+		if b.close != nil {
+			r.CloseMarker = markerLine(b.close.text)
+			r.Text = content[b.open.end:b.close.offset]
+			r.End = b.close.end
+		}
+		regions = append(regions, r)
+	}
+	return regions
+}
+
+func isRegionOpen(marker string) bool {
+	m := regionOpenRegexp.FindStringSubmatch(marker)
+	return m != nil && !reservedDirectives[m[1]]
+}
+
+// WithHash returns the open marker of the region with the input hash set, keeping the other attributes as written
+func (r *Region) WithHash(hash string) string {
+	attr := fmt.Sprintf(` hash="%s"`, hash)
+	if hashAttrRegexp.MatchString(r.OpenMarker) {
+		return hashAttrRegexp.ReplaceAllLiteralString(r.OpenMarker, attr)
+	}
+	body := strings.TrimSpace(strings.TrimSuffix(r.OpenMarker, "-->"))
+	return body + attr + " -->"
+}
+
+// WithoutRegions returns the content between start and end without the directive regions in it
+func WithoutRegions(content string, regions []*Region, start, end int) string {
+	var b strings.Builder
+	pos := start
+	for _, r := range regions {
+		if r.End <= pos || r.Offset >= end {
+			continue
+		}
+		if r.Offset > pos {
+			b.WriteString(content[pos:r.Offset])
+		}
+		pos = max(pos, r.End)
+	}
+	if pos < end {
+		b.WriteString(content[pos:end])
+	}
+	return b.String()
+}
@@ -0,0 +1,39 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package file
+
+import "testing"
+
+func TestRegions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		texts   []string
+		closed  []bool
+		lines   []int
+	}{
+		{"closed region", "# T\n\n<!-- mdai:summarize -->\nsummary\n\n<!-- /mdai -->\n", []string{"summary\n\n"}, []bool{true}, []int{3}},
+		{"text ending in html", "<!-- mdai:summarize -->\n<div>x</div>\n<!-- /mdai -->\nafter\n", []string{"<div>x</div>\n"}, []bool{true}, []int{1}},
+		{"text leaving a fence open", "<!-- mdai:summarize -->\n```\ncode\n<!-- /mdai -->\n<!-- mdai:tldr -->\n", []string{"```\ncode\n", ""}, []bool{true, false}, []int{1, 5}},
+		{"next region before the close marker", "<!-- mdai:summarize -->\ntext\n<!-- mdai:tldr -->\n<!-- /mdai -->\n", []string{"", ""}, []bool{false, true}, []int{1, 3}},
+		{"reserved directives", "<!-- mdai:answer -->\n<!-- mdai:marker name=\"x\" -->\n", nil, nil, nil},
+		{"marker in fenced code", "```\n<!-- mdai:summarize -->\n```\n", nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regions := Regions(tt.content)
+			if len(regions) != len(tt.texts) {
+				t.Fatalf("Regions() = %d regions, want %d", len(regions), len(tt.texts))
+			}
+			for i, r := range regions {
+				if r.Text != tt.texts[i] || (r.CloseMarker != "") != tt.closed[i] || r.Line != tt.lines[i] {
+					t.Errorf("region %d = %q (close %q, line %d), want %q (closed %v, line %d)", i, r.Text, r.CloseMarker, r.Line, tt.texts[i], tt.closed[i], tt.lines[i])
+				}
+				if !tt.closed[i] && tt.content[r.Offset:r.End] != r.OpenMarker+"\n" {
+					t.Errorf("unclosed region %d covers %q, want the open marker only", i, tt.content[r.Offset:r.End])
+				}
+			}
+		})
+	}
+}
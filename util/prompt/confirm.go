@@ -0,0 +1,46 @@
+/*
+Copyright © 2025 koooyooo
+*/
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// stdin is shared by the questions, so that input buffered while reading one answer is kept for the next.
+// The mutex keeps concurrent questions from interleaving.
+var (
+	stdinMu sync.Mutex
+	stdin   = bufio.NewReader(os.Stdin)
+)
+
+// Confirm asks a yes/no question on stderr and reads the answer from stdin.
+// Anything other than "y" or "yes" (including EOF) is treated as no.
+func Confirm(question string) (bool, error) {
+	stdinMu.Lock()
+	defer stdinMu.Unlock()
+	return confirm(stdin, os.Stderr, question)
+}
+
+func confirm(r io.Reader, w io.Writer, question string) (bool, error) {
+	if _, err := fmt.Fprintf(w, "%s [y/N]: ", question); err != nil {
+		return false, err
+	}
+
+	answer, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read answer: %v", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}